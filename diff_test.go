@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff_Equal(t *testing.T) {
+	if d := unifiedDiff("a", "b", "x\ny\n", "x\ny\n"); d != "" {
+		t.Fatalf("expected no diff, got %q", d)
+	}
+}
+
+func TestUnifiedDiff_Change(t *testing.T) {
+	old := "package x\n\nconst PkgName = \"x\"\nconst PkgVersion = \"v1.0.0\"\n"
+	new := "package x\n\nconst PkgName = \"x\"\nconst PkgVersion = \"v1.0.1\"\n"
+	want := `--- a
++++ b
+@@ -1,4 +1,4 @@
+ package x
+ 
+ const PkgName = "x"
+-const PkgVersion = "v1.0.0"
++const PkgVersion = "v1.0.1"
+`
+	if d := unifiedDiff("a", "b", old, new); d != want {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+}
+
+func TestUnifiedDiff_EmptyAndNoNewline(t *testing.T) {
+	want := "--- a\n+++ b\n@@ -0,0 +1 @@\n+v1\n\\ No newline at end of file\n"
+	if d := unifiedDiff("a", "b", "", "v1"); d != want {
+		t.Fatalf("unexpected diff:\n%q", d)
+	}
+	want = "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n"
+	if d := unifiedDiff("a", "b", "x\ny\n", ""); d != want {
+		t.Fatalf("unexpected diff:\n%q", d)
+	}
+}
+
+func TestUnifiedDiff_SeparateHunks(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line%d\n", i))
+	}
+	old := strings.Join(lines, "")
+	lines[1] = "changed2\n"
+	lines[17] = "changed18\n"
+	new := strings.Join(lines, "")
+	d := unifiedDiff("a", "b", old, new)
+	if !strings.Contains(d, "@@ -1,5 +1,5 @@\n line1\n-line2\n+changed2\n line3\n") {
+		t.Fatalf("missing first hunk:\n%s", d)
+	}
+	if !strings.Contains(d, "@@ -15,6 +15,6 @@\n line15\n line16\n line17\n-line18\n+changed18\n line19\n line20\n") {
+		t.Fatalf("missing second hunk:\n%s", d)
+	}
+	if strings.Count(d, "@@ -") != 2 {
+		t.Fatalf("expected two hunks:\n%s", d)
+	}
+}
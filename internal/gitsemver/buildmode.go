@@ -0,0 +1,46 @@
+package gitsemver
+
+import "fmt"
+
+// BuildMode selects how GitSemVer computes the build number.
+type BuildMode int
+
+const (
+	// BuildAll uses the CI build counter if available, otherwise the
+	// number of commits reachable from HEAD.
+	BuildAll BuildMode = iota
+	// BuildPath counts the commits reachable from HEAD that touch
+	// TreePath. CI build counters are ignored.
+	BuildPath
+	// BuildHeight counts the commits since the matched tag, like
+	// "git describe". If TreePath is set, only commits touching it
+	// are counted. CI build counters are ignored.
+	BuildHeight
+)
+
+var buildModeNames = []string{
+	BuildAll:    "all",
+	BuildPath:   "path",
+	BuildHeight: "height",
+}
+
+func (mode BuildMode) String() string {
+	if mode >= 0 && int(mode) < len(buildModeNames) {
+		return buildModeNames[mode]
+	}
+	return fmt.Sprintf("BuildMode(%d)", int(mode))
+}
+
+// ParseBuildMode returns the BuildMode with the given name.
+// An empty name selects BuildAll.
+func ParseBuildMode(name string) (mode BuildMode, err error) {
+	if name != "" {
+		for i, s := range buildModeNames {
+			if s == name {
+				return BuildMode(i), nil
+			}
+		}
+		err = fmt.Errorf("unknown build mode %q", name)
+	}
+	return
+}
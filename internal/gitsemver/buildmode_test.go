@@ -0,0 +1,52 @@
+package gitsemver_test
+
+import (
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_ParseBuildMode(t *testing.T) {
+	for _, mode := range []gitsemver.BuildMode{gitsemver.BuildAll, gitsemver.BuildPath, gitsemver.BuildHeight} {
+		got, err := gitsemver.ParseBuildMode(mode.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		isEqual(t, mode, got)
+	}
+	got, err := gitsemver.ParseBuildMode("")
+	isEqual(t, err, nil)
+	isEqual(t, gitsemver.BuildAll, got)
+	if _, err = gitsemver.ParseBuildMode("bogus"); err == nil {
+		t.Fatal("expected error for unknown build mode")
+	}
+	isEqual(t, "BuildMode(99)", gitsemver.BuildMode(99).String())
+}
+
+func Test_VersionStringer_GetVersion_BuildMode(t *testing.T) {
+	env := MockEnvironment{"GITHUB_RUN_NUMBER": "789"}
+	git := &MockGitter{}
+	vs := gitsemver.GitSemVer{Git: git, Env: env}
+	vi, err := vs.GetVersion(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "789", vi.Build)
+
+	vs = gitsemver.GitSemVer{Git: git, Env: env, BuildMode: gitsemver.BuildPath, TreePath: "tools/cli"}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "6", vi.Build)
+	isEqual(t, "", git.countSince)
+	isEqual(t, "tools/cli", git.countPath)
+
+	vs = gitsemver.GitSemVer{Git: git, Env: env, BuildMode: gitsemver.BuildHeight}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "3", vi.Build)
+	isEqual(t, "commit-6", git.countSince)
+	isEqual(t, "", git.countPath)
+	isEqual(t, "v6.0.0-main.3", vi.Version())
+}
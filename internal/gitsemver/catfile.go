@@ -0,0 +1,338 @@
+package gitsemver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catFileWaitTimeout is how long Close waits for a cat-file process to exit before killing it.
+const catFileWaitTimeout = 5 * time.Second
+
+// CatFile keeps one long-lived "git cat-file --batch-command" process per
+// repository, so that object queries don't start a new git process each.
+// A process that dies is restarted on the next query. If git is too old for
+// --batch-command, "git cat-file --batch" is used instead. The zero value is
+// ready to use, and Close stops the processes.
+type CatFile struct {
+	mu    sync.Mutex
+	procs map[string]*catFileProc
+	batch bool  // true if a new --batch-command process failed, so --batch is used
+	err   error // set if a new --batch process failed too, as then cat-file won't work
+}
+
+// catFileProc is a running "git cat-file --batch-command" or "--batch" process.
+type catFileProc struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr bytes.Buffer
+	batch  bool // true for --batch, which takes object names and always prints the contents
+}
+
+// catFileObject is the result of an "info" or "contents" command.
+// The hash is empty if the object is missing or ambiguous.
+type catFileObject struct {
+	hash string
+	kind string
+	data []byte
+}
+
+// start starts a cat-file process for repo.
+func (cf *CatFile) start(dg DefaultGitter, repo string) (p *catFileProc, err error) {
+	mode := "--batch-command"
+	if cf.batch {
+		mode = "--batch"
+	}
+	p = &catFileProc{batch: cf.batch}
+	p.cmd = exec.Command(dg.Git, "-C", repo, "cat-file", mode) /* #nosec G204 */
+	p.cmd.Stderr = &p.stderr
+	var stdout io.ReadCloser
+	if p.stdin, err = p.cmd.StdinPipe(); err == nil {
+		if stdout, err = p.cmd.StdoutPipe(); err == nil {
+			p.stdout = bufio.NewReader(stdout)
+			if err = p.cmd.Start(); err == nil {
+				if dg.DebugOut != nil {
+					fmt.Fprintf(dg.DebugOut, "%q => started\n", strings.Join(p.cmd.Args, " "))
+					MaybeSync(dg.DebugOut)
+				}
+			}
+		}
+	}
+	if err != nil {
+		err = NewErrGitExec(dg.Git, p.cmd.Args[1:], err, "")
+		p = nil
+	}
+	return
+}
+
+// stop closes the standard input of the process and waits for it to exit,
+// killing it if it doesn't within catFileWaitTimeout.
+func (p *catFileProc) stop() {
+	_ = p.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		_ = p.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(catFileWaitTimeout):
+		_ = p.cmd.Process.Kill()
+		<-done
+	}
+}
+
+// query sends the commands and reads one result for each. If withData is
+// true, the commands must be "contents" commands, otherwise "info" commands.
+func (p *catFileProc) query(commands []string, withData bool) (objects []catFileObject, err error) {
+	// write from another goroutine so a full stdout pipe can't deadlock us
+	written := make(chan error, 1)
+	go func() {
+		var sb strings.Builder
+		for _, command := range commands {
+			if p.batch {
+				_, command, _ = strings.Cut(command, " ")
+			}
+			sb.WriteString(command)
+			sb.WriteByte('\n')
+		}
+		_, e := io.WriteString(p.stdin, sb.String())
+		written <- e
+	}()
+	for len(objects) < len(commands) && err == nil {
+		var line string
+		if line, err = p.stdout.ReadString('\n'); err == nil {
+			var obj catFileObject
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) >= 2 && (fields[len(fields)-1] == "missing" || fields[len(fields)-1] == "ambiguous"):
+			case len(fields) == 3:
+				obj.hash, obj.kind = fields[0], fields[1]
+				if withData || p.batch {
+					var size int
+					if size, err = strconv.Atoi(fields[2]); err == nil {
+						obj.data = make([]byte, size+1)
+						if _, err = io.ReadFull(p.stdout, obj.data); err == nil {
+							obj.data = obj.data[:size]
+						}
+					}
+					if !withData {
+						obj.data = nil
+					}
+				}
+			default:
+				err = fmt.Errorf("unexpected cat-file output %q", line)
+			}
+			objects = append(objects, obj)
+		}
+	}
+	if err != nil {
+		// the process may be blocked writing output we won't read
+		_ = p.cmd.Process.Kill()
+	}
+	if e := <-written; err == nil {
+		err = e
+	}
+	if err != nil {
+		objects = nil
+	}
+	return
+}
+
+// query runs the commands in the process for repo, starting it if needed.
+// If the process fails, it is stopped and the commands are retried in a new one.
+// If a new --batch-command process fails, --batch is tried, and if that fails
+// too, cat-file isn't used again.
+func (cf *CatFile) query(dg DefaultGitter, repo string, commands []string, withData bool) (objects []catFileObject, err error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if cf.err != nil {
+		return nil, cf.err
+	}
+	if cf.procs == nil {
+		cf.procs = map[string]*catFileProc{}
+	}
+	for {
+		p := cf.procs[repo]
+		started := p == nil
+		if started {
+			p, err = cf.start(dg, repo)
+		}
+		if err == nil {
+			cf.procs[repo] = p
+			if objects, err = p.query(commands, withData); err == nil {
+				return
+			}
+			delete(cf.procs, repo)
+			p.stop()
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = NewErrGitExec(dg.Git, p.cmd.Args[1:], err, strings.TrimSpace(p.stderr.String()))
+			}
+		}
+		if dg.DebugOut != nil {
+			fmt.Fprintf(dg.DebugOut, "cat-file => %v\n", err)
+			MaybeSync(dg.DebugOut)
+		}
+		if started {
+			if !cf.batch {
+				// git before 2.36 doesn't have --batch-command
+				cf.batch = true
+				continue
+			}
+			cf.err = err
+			return
+		}
+	}
+}
+
+// Close stops all the cat-file processes.
+func (cf *CatFile) Close() error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	for repo, p := range cf.procs {
+		p.stop()
+		delete(cf.procs, repo)
+	}
+	return nil
+}
+
+// catFileInfo returns the object names and types of the objects, using
+// dg.CatFile. The object name is empty for objects that don't exist.
+func (dg DefaultGitter) catFileInfo(repo string, objects ...string) (results []catFileObject, err error) {
+	commands := make([]string, len(objects))
+	for i, obj := range objects {
+		commands[i] = "info " + obj
+	}
+	return dg.CatFile.query(dg, repo, commands, false)
+}
+
+// catFileCommit returns the commit that rev resolves to, using dg.CatFile.
+// The commit is nil if rev doesn't exist.
+func (dg DefaultGitter) catFileCommit(repo, rev string) (c *gitCommit, err error) {
+	var results []catFileObject
+	if results, err = dg.CatFile.query(dg, repo, []string{"contents " + rev + "^{commit}"}, true); err == nil {
+		if results[0].hash != "" {
+			c = parseCommit(results[0].data)
+		}
+	}
+	return
+}
+
+// catFileHashes returns the commit hashes of the tags and the hashes of path in them,
+// or of their root trees if path is empty, using dg.CatFile. Tags that don't exist are left out.
+func (dg DefaultGitter) catFileHashes(repo string, tags []string, path string) (hashes []GitTag, err error) {
+	objects := make([]string, 0, len(tags)*3)
+	for _, tag := range tags {
+		if path == "" {
+			objects = append(objects, tag+"^{commit}", tag+"^{tree}", tag)
+		} else {
+			objects = append(objects, tag+"^{commit}", tag+":"+path, tag)
+		}
+	}
+	var results []catFileObject
+	if results, err = dg.catFileInfo(repo, objects...); err == nil {
+		hashes = make([]GitTag, 0, len(tags))
+		for i, tag := range tags {
+			if commit := results[i*3].hash; commit != "" {
+				tree := results[i*3+1].hash
+				if tree == "" {
+					tree = emptyTreeHash(commit)
+				}
+				hashes = append(hashes, GitTag{Tag: tag, Commit: commit, Tree: tree, Annotated: results[i*3+2].kind == "tag"})
+			}
+		}
+	}
+	return
+}
+
+// catFileCommits reads the commits reachable from the revs into commits, using
+// dg.CatFile, with one query for all the parents not yet read at each step.
+// Like "git rev-list", the commits at the boundary of a shallow clone have no
+// parents. Returns the commit hash of each rev, or an empty string if it
+// doesn't exist.
+func (dg DefaultGitter) catFileCommits(repo string, commits map[string]*gitCommit, revs ...string) (hashes []string, err error) {
+	var r *gitRepo
+	if r, err = openGitRepo(repo); err == nil {
+		commands := make([]string, len(revs))
+		for i, rev := range revs {
+			commands[i] = "contents " + rev + "^{commit}"
+		}
+		for first := true; len(commands) > 0 && err == nil; first = false {
+			var results []catFileObject
+			if results, err = dg.CatFile.query(dg, repo, commands, true); err == nil {
+				var next []string
+				for i, obj := range results {
+					if first {
+						hashes = append(hashes, obj.hash)
+					} else if obj.hash == "" {
+						return nil, fmt.Errorf("%s: missing commit", strings.TrimPrefix(commands[i], "contents "))
+					}
+					if obj.hash != "" && commits[obj.hash] == nil {
+						c := parseCommit(obj.data)
+						if r.shallow[obj.hash] {
+							c.parents = nil
+						}
+						commits[obj.hash] = c
+						for _, parent := range c.parents {
+							if _, ok := commits[parent]; !ok {
+								commits[parent] = nil // queued
+								next = append(next, "contents "+parent)
+							}
+						}
+					}
+				}
+				commands = next
+			}
+		}
+	}
+	return
+}
+
+// catFileGraph returns the history reachable from rev, using dg.CatFile.
+// The graph is nil if rev doesn't exist.
+func (dg DefaultGitter) catFileGraph(repo, rev string) (g *commitGraph, err error) {
+	commits := map[string]*gitCommit{}
+	var hashes []string
+	if hashes, err = dg.catFileCommits(repo, commits, rev); err == nil && hashes[0] != "" {
+		g, err = walkCommitGraph(hashes[0], func(hash string) (*gitCommit, error) { return commits[hash], nil })
+	}
+	return
+}
+
+// catFileRange returns the commits reachable from to but not from from, or all
+// commits reachable from to if from is empty, using dg.CatFile. The result is
+// nil if to or from don't exist.
+func (dg DefaultGitter) catFileRange(repo, from, to string) (commits []*gitCommit, err error) {
+	revs := []string{to}
+	if from != "" {
+		revs = append(revs, from)
+	}
+	all := map[string]*gitCommit{}
+	var hashes []string
+	if hashes, err = dg.catFileCommits(repo, all, revs...); err == nil && !slices.Contains(hashes, "") {
+		g := newCommitGraph()
+		for hash, c := range all {
+			g.add(hash, c.when.Unix(), c.parents)
+		}
+		var exclude map[string]bool
+		if from != "" {
+			exclude = g.reachable(hashes[1])
+		}
+		commits = []*gitCommit{}
+		for hash := range g.reachable(hashes[0]) {
+			if !exclude[hash] {
+				commits = append(commits, all[hash])
+			}
+		}
+	}
+	return
+}
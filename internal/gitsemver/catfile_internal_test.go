@@ -0,0 +1,35 @@
+package gitsemver
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_CatFile_RestartsDeadProcess(t *testing.T) {
+	repo := t.TempDir()
+	if b, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, b)
+	}
+	var buf bytes.Buffer
+	dg := DefaultGitter{Git: "git", DebugOut: &buf, CatFile: &CatFile{}}
+	defer dg.Close()
+	emptyTree := emptyTreeHash("")
+	for i := 0; i < 2; i++ {
+		results, err := dg.catFileInfo(repo, emptyTree)
+		if err != nil || results[0].hash != emptyTree || results[0].kind != "tree" {
+			t.Fatalf("catFileInfo() = %v, %v", results, err)
+		}
+		p := dg.CatFile.procs[repo]
+		if err = p.cmd.Process.Kill(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "=> started"); n != 2 {
+		t.Errorf("started %d cat-file processes, want 2\n%s", n, buf.String())
+	}
+	if dg.CatFile.err != nil {
+		t.Error(dg.CatFile.err)
+	}
+}
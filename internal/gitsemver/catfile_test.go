@@ -0,0 +1,181 @@
+package gitsemver_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_DefaultGitter_CatFile(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	runGit(t, repo, nil, "-c", "advice.nestedTag=false", "tag", "-a", "-m", "nested", "v1.2.0", "v1.1.0")
+	dg := gitsemver.DefaultGitter{Git: "git"}
+	var buf bytes.Buffer
+	cf := gitsemver.DefaultGitter{Git: "git", DebugOut: &buf, CatFile: &gitsemver.CatFile{}}
+	defer cf.Close()
+
+	tags := []string{"v1.0.0", "v1.0.1", "v1.1.0", "v1.2.0", "tools/cli/v0.1.0"}
+	for _, path := range []string{"", "tools/cli", "missing"} {
+		want, err1 := dg.GetPathHashesBatch(repo, tags, path)
+		got, err2 := cf.GetPathHashesBatch(repo, tags, path)
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("GetPathHashesBatch(%q) = %v, %v; want %v, %v", path, got, err2, want, err1)
+		}
+	}
+	wantCommit, wantTree, err1 := dg.GetHashes(repo, "v1.2.0")
+	gotCommit, gotTree, err2 := cf.GetHashes(repo, "v1.2.0")
+	if err1 != nil || err2 != nil || gotCommit != wantCommit || gotTree != wantTree {
+		t.Errorf("GetHashes() = %q, %q, %v; want %q, %q, %v", gotCommit, gotTree, err2, wantCommit, wantTree, err1)
+	}
+	wantHead, err1 := dg.GetHead(repo, false)
+	gotHead, err2 := cf.GetHead(repo, false)
+	if err1 != nil || err2 != nil || gotHead != wantHead {
+		t.Errorf("GetHead() = %q, %v; want %q, %v", gotHead, err2, wantHead, err1)
+	}
+	wantTime, err1 := dg.GetCommitTime(repo, "v1.0.1")
+	gotTime, err2 := cf.GetCommitTime(repo, "v1.0.1")
+	if err1 != nil || err2 != nil || !gotTime.Equal(wantTime) || gotTime.Format(time.RFC3339) != wantTime.Format(time.RFC3339) {
+		t.Errorf("GetCommitTime() = %v, %v; want %v, %v", gotTime, err2, wantTime, err1)
+	}
+	if hashes, err := cf.GetHashesBatch(repo, []string{"v9.9.9", "v1.0.0"}); err != nil || len(hashes) != 1 {
+		t.Errorf("GetHashesBatch() = %v, %v; want only v1.0.0", hashes, err)
+	}
+	if _, _, err := cf.GetHashes(repo, "v9.9.9"); err == nil {
+		t.Error("expected error for missing tag")
+	}
+	if n := strings.Count(buf.String(), "=> started"); n != 1 {
+		t.Errorf("started %d cat-file processes\n%s", n, buf.String())
+	}
+
+	// history walks read the commits from the same process
+	compareHistory(t, dg, cf, repo)
+	if strings.Contains(buf.String(), "rev-list") || strings.Contains(buf.String(), " log ") {
+		t.Errorf("history walked without cat-file\n%s", buf.String())
+	}
+
+	// new objects and refs are seen by the running process
+	commitAt(t, repo, "a.txt", "new\n", "c8", "2020-01-08T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.3.0")
+	if commit, _, err := cf.GetHashes(repo, "v1.3.0"); err != nil || commit != runGit(t, repo, nil, "rev-parse", "HEAD") {
+		t.Errorf("GetHashes(v1.3.0) = %q, %v", commit, err)
+	}
+
+	if err := cf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if head, err := cf.GetHead(repo, false); err != nil || head == "" {
+		t.Errorf("GetHead() after Close = %q, %v", head, err)
+	}
+	if n := strings.Count(buf.String(), "=> started"); n != 2 {
+		t.Errorf("started %d cat-file processes\n%s", n, buf.String())
+	}
+}
+
+// compareHistory checks that cf returns what dg does for the history walks.
+func compareHistory(t *testing.T, dg, cf gitsemver.DefaultGitter, repo string) {
+	t.Helper()
+	tags, err := dg.GetTagHashes(repo, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTag, wantDistance, err1 := dg.GetNearestTag(repo, tags, "HEAD")
+	gotTag, gotDistance, err2 := cf.GetNearestTag(repo, tags, "HEAD")
+	if err1 != nil || err2 != nil || gotTag != wantTag || gotDistance != wantDistance {
+		t.Errorf("GetNearestTag() = %q, %d, %v; want %q, %d, %v", gotTag, gotDistance, err2, wantTag, wantDistance, err1)
+	}
+	for _, since := range []string{"", "v1.0.0", "v1.0.1", "HEAD"} {
+		want, err1 := dg.CountCommits(repo, "HEAD", since, "")
+		got, err2 := cf.CountCommits(repo, "HEAD", since, "")
+		if err1 != nil || err2 != nil || got != want {
+			t.Errorf("CountCommits(%q) = %d, %v; want %d, %v", since, got, err2, want, err1)
+		}
+	}
+	for _, rng := range [][2]string{{"", "HEAD"}, {"v1.0.0", "HEAD"}, {"v1.1.0", "HEAD~1"}} {
+		want, err1 := dg.GetCommitMessages(repo, rng[0], rng[1])
+		got, err2 := cf.GetCommitMessages(repo, rng[0], rng[1])
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("GetCommitMessages(%q, %q) = %q, %v; want %q, %v", rng[0], rng[1], got, err2, want, err1)
+		}
+	}
+	wantBuild, err1 := dg.GetBuild(repo)
+	gotBuild, err2 := cf.GetBuild(repo)
+	if err1 != nil || err2 != nil || gotBuild != wantBuild {
+		t.Errorf("GetBuild() = %q, %v; want %q, %v", gotBuild, err2, wantBuild, err1)
+	}
+}
+
+func Test_DefaultGitter_CatFile_Shallow(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	runGit(t, repo, nil, "clone", "-q", "--depth", "3", "--no-single-branch", "file://"+repo, shallow)
+	runGit(t, shallow, nil, "fetch", "-q", "--depth", "3", "origin", "+refs/tags/*:refs/tags/*")
+	dg := gitsemver.DefaultGitter{Git: "git"}
+	var buf bytes.Buffer
+	cf := gitsemver.DefaultGitter{Git: "git", DebugOut: &buf, CatFile: &gitsemver.CatFile{}}
+	defer cf.Close()
+	compareHistory(t, dg, cf, shallow)
+	if strings.Contains(buf.String(), "rev-list") || strings.Contains(buf.String(), " log ") {
+		t.Errorf("history walked without cat-file\n%s", buf.String())
+	}
+}
+
+func Test_DefaultGitter_CatFile_Batch(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	gitBin := filepath.Join(t.TempDir(), "git")
+	// like git before 2.36
+	script := "#!/bin/sh\ncase \"$*\" in *--batch-command*) echo \"unknown option\" >&2; exit 129;; esac\nexec git \"$@\"\n"
+	if err := os.WriteFile(gitBin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dg := gitsemver.DefaultGitter{Git: "git"}
+	var buf bytes.Buffer
+	cf := gitsemver.DefaultGitter{Git: gitBin, DebugOut: &buf, CatFile: &gitsemver.CatFile{}}
+	defer cf.Close()
+
+	tags := []string{"v1.0.0", "v1.0.1", "v1.1.0", "tools/cli/v0.1.0"}
+	want, err1 := dg.GetPathHashesBatch(repo, tags, "")
+	got, err2 := cf.GetPathHashesBatch(repo, tags, "")
+	if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+		t.Errorf("GetPathHashesBatch() = %v, %v; want %v, %v", got, err2, want, err1)
+	}
+	compareHistory(t, dg, cf, repo)
+	if !strings.Contains(buf.String(), "unknown option") {
+		t.Errorf("missing --batch-command error\n%s", buf.String())
+	}
+	if n := strings.Count(buf.String(), "=> started"); n != 2 {
+		t.Errorf("started %d cat-file processes\n%s", n, buf.String())
+	}
+	if strings.Contains(buf.String(), "rev-parse") || strings.Contains(buf.String(), "rev-list") {
+		t.Errorf("fell back to separate git commands\n%s", buf.String())
+	}
+}
+
+func Test_DefaultGitter_CatFile_FallsBack(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	gitBin := filepath.Join(t.TempDir(), "git")
+	script := "#!/bin/sh\ncase \"$*\" in *cat-file*) echo broken >&2; exit 1;; esac\nexec git \"$@\"\n"
+	if err := os.WriteFile(gitBin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	cf := gitsemver.DefaultGitter{Git: gitBin, DebugOut: &buf, CatFile: &gitsemver.CatFile{}}
+	defer cf.Close()
+	for i := 0; i < 2; i++ {
+		if commit, tree, err := cf.GetHashes(repo, "v1.1.0"); err != nil || commit == "" || tree == "" {
+			t.Errorf("GetHashes() = %q, %q, %v", commit, tree, err)
+		}
+	}
+	// --batch-command, then --batch
+	if n := strings.Count(buf.String(), "=> started"); n != 2 {
+		t.Errorf("started %d cat-file processes\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "broken") {
+		t.Errorf("missing cat-file error\n%s", buf.String())
+	}
+}
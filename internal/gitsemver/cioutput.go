@@ -0,0 +1,127 @@
+package gitsemver
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GitLabDotenvFile is the name of the GitLab dotenv report written by WriteCIOutput.
+// It is placed in CI_PROJECT_DIR, or in the repository if that is not set.
+const GitLabDotenvFile = "gitsemver.env"
+
+// ciEnvPrefix is prepended to the upper-cased names of environment variables.
+const ciEnvPrefix = "GITSEMVER_"
+
+type ciVar struct {
+	name  string
+	value string
+}
+
+func ciVars(vi *VersionInfo, version string) []ciVar {
+	return []ciVar{
+		{"version", version},
+		{"tag", vi.Tag},
+		{"branch", vi.Branch},
+		{"build", vi.Build},
+		{"is_release", strconv.FormatBool(vi.IsRelease)},
+	}
+}
+
+func appendFile(fileName, text string) (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600); err == nil /* #nosec G304 */ {
+		_, err = f.WriteString(text)
+		err = errors.Join(err, f.Close())
+	}
+	return
+}
+
+// formatGitHub returns vars using the GitHub Actions multiline syntax,
+// with a random delimiter that doesn't occur in any of the values.
+func formatGitHub(vars []ciVar, prefix string) string {
+	var delim string
+	for delim == "" {
+		delim = fmt.Sprintf("ghadelimiter_%x", rand.Uint64()) // #nosec G404
+		for _, v := range vars {
+			if strings.Contains(v.value, delim) {
+				delim = ""
+			}
+		}
+	}
+	var sb strings.Builder
+	for _, v := range vars {
+		name := v.name
+		if prefix != "" {
+			name = prefix + strings.ToUpper(name)
+		}
+		fmt.Fprintf(&sb, "%s<<%s\n%s\n%s\n", name, delim, v.value, delim)
+	}
+	return sb.String()
+}
+
+// formatDotenv returns vars in the GitLab dotenv report format, which
+// does not support multiline values.
+func formatDotenv(vars []ciVar) (s string, err error) {
+	var sb strings.Builder
+	for _, v := range vars {
+		if strings.ContainsAny(v.value, "\r\n") {
+			return "", fmt.Errorf("dotenv value for %s contains a newline", v.name)
+		}
+		fmt.Fprintf(&sb, "%s%s=%s\n", ciEnvPrefix, strings.ToUpper(v.name), v.value)
+	}
+	return sb.String(), nil
+}
+
+// errNoCI is returned by WriteCIOutput and CheckCIOutput without a CI system.
+var errNoCI = errors.New("no supported CI environment detected")
+
+// CheckCIOutput returns an error if WriteCIOutput can't detect a CI system,
+// so that this can be found out before anything is tagged or pushed.
+func (vs *GitSemVer) CheckCIOutput() (err error) {
+	if !vs.isGitHub() && !vs.isGitLab() {
+		err = errNoCI
+	}
+	return
+}
+
+// WriteCIOutput publishes the version, tag, branch, build and is_release values
+// to the CI system, with version being the version as printed. On GitHub they
+// are appended to the GITHUB_OUTPUT file as step outputs and to the GITHUB_ENV
+// file as GITSEMVER_* environment variables. On GitLab a dotenv report named
+// GitLabDotenvFile is written with the GITSEMVER_* variables. Returns an error
+// if no CI system is detected.
+func (vs *GitSemVer) WriteCIOutput(repo string, vi *VersionInfo, version string) (err error) {
+	vars := ciVars(vi, version)
+	ghOutput := vs.Env.Getenv("GITHUB_OUTPUT")
+	ghEnv := vs.Env.Getenv("GITHUB_ENV")
+	switch {
+	case vs.isGitHub():
+		if ghOutput != "" {
+			vs.Debug("ci-output: GitHub outputs %q\n", ghOutput)
+			err = appendFile(ghOutput, formatGitHub(vars, ""))
+		}
+		if err == nil && ghEnv != "" {
+			vs.Debug("ci-output: GitHub environment %q\n", ghEnv)
+			err = appendFile(ghEnv, formatGitHub(vars, ciEnvPrefix))
+		}
+	case vs.isGitLab():
+		dir := vs.Env.Getenv("CI_PROJECT_DIR")
+		if dir == "" {
+			dir = repo
+		}
+		var text string
+		if text, err = formatDotenv(vars); err == nil {
+			fileName := filepath.Join(dir, GitLabDotenvFile)
+			vs.Debug("ci-output: GitLab dotenv %q\n", fileName)
+			err = os.WriteFile(fileName, []byte(text), 0o600) // #nosec G306
+		}
+	default:
+		err = errNoCI
+	}
+	return
+}
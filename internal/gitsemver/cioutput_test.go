@@ -0,0 +1,114 @@
+package gitsemver_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+var reGitHubEntry = regexp.MustCompile(`(?m)^([A-Za-z_]+)<<(ghadelimiter_[0-9a-f]+)\n(.*)\n(ghadelimiter_[0-9a-f]+)$`)
+
+func readGitHubFile(t *testing.T, fileName string) map[string]string {
+	t.Helper()
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{}
+	for _, m := range reGitHubEntry.FindAllStringSubmatch(string(b), -1) {
+		if m[2] != m[4] {
+			t.Fatalf("mismatched delimiters in %q", b)
+		}
+		vars[m[1]] = m[3]
+	}
+	return vars
+}
+
+func Test_GitSemVer_WriteCIOutput_GitHub(t *testing.T) {
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "output")
+	envFile := filepath.Join(dir, "env")
+	if err := os.WriteFile(outFile, []byte("previous=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true", "GITHUB_OUTPUT": outFile, "GITHUB_ENV": envFile}
+	vs := gitsemver.GitSemVer{Git: &MockGitter{}, Env: env}
+	vi := &gitsemver.VersionInfo{Tag: "v1.2.3", Branch: "feature/x", Build: "7"}
+	if err := vs.WriteCIOutput(".", vi, vi.Version()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isTrue(t, strings.HasPrefix(string(b), "previous=1\n"))
+	out := readGitHubFile(t, outFile)
+	isEqual(t, 5, len(out))
+	isEqual(t, "v1.2.3-feature-x.7", out["version"])
+	isEqual(t, "v1.2.3", out["tag"])
+	isEqual(t, "feature/x", out["branch"])
+	isEqual(t, "7", out["build"])
+	isEqual(t, "false", out["is_release"])
+
+	envs := readGitHubFile(t, envFile)
+	isEqual(t, 5, len(envs))
+	isEqual(t, "v1.2.3-feature-x.7", envs["GITSEMVER_VERSION"])
+	isEqual(t, "false", envs["GITSEMVER_IS_RELEASE"])
+}
+
+func Test_GitSemVer_WriteCIOutput_GitLab(t *testing.T) {
+	dir := t.TempDir()
+	env := MockEnvironment{"GITLAB_CI": "true", "CI_COMMIT_REF_NAME": "main", "CI_PROJECT_DIR": dir}
+	vs := gitsemver.GitSemVer{Git: &MockGitter{}, Env: env}
+	vi := &gitsemver.VersionInfo{Tag: "v1.2.3", Branch: "main", Build: "7", SameTree: true, IsRelease: true}
+	if err := vs.WriteCIOutput(".", vi, vi.Version()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, gitsemver.GitLabDotenvFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "GITSEMVER_VERSION=v1.2.3\nGITSEMVER_TAG=v1.2.3\nGITSEMVER_BRANCH=main\nGITSEMVER_BUILD=7\nGITSEMVER_IS_RELEASE=true\n"
+	isEqual(t, want, string(b))
+	isEqual(t, vs.CheckCIOutput(), nil)
+
+	// the version is published as given, as with -safe
+	vi.IsRelease = false
+	if err := vs.WriteCIOutput(".", vi, "v1.2.3_main.7"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = os.ReadFile(filepath.Join(dir, gitsemver.GitLabDotenvFile)); err != nil {
+		t.Fatal(err)
+	}
+	isTrue(t, strings.HasPrefix(string(b), "GITSEMVER_VERSION=v1.2.3_main.7\n"))
+
+	vi.Branch = "bad\nbranch"
+	if err := vs.WriteCIOutput(".", vi, vi.Version()); err == nil {
+		t.Error("expected error for multiline dotenv value")
+	}
+}
+
+func Test_GitSemVer_WriteCIOutput_Errors(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "v1.2.3"}
+	vs := gitsemver.GitSemVer{Git: &MockGitter{}, Env: MockEnvironment{}}
+	if err := vs.WriteCIOutput(".", vi, vi.Version()); err == nil {
+		t.Error("expected error without a CI environment")
+	}
+	if err := vs.CheckCIOutput(); err == nil {
+		t.Error("expected CheckCIOutput error without a CI environment")
+	}
+	// the CI system is detected the same way as for the branch name
+	vs.Env = MockEnvironment{"GITHUB_OUTPUT": filepath.Join(t.TempDir(), "output"), "CI_COMMIT_REF_NAME": "main"}
+	if err := vs.CheckCIOutput(); err == nil {
+		t.Error("expected CheckCIOutput error without GITHUB_ACTIONS or GITLAB_CI")
+	}
+	vs.Env = MockEnvironment{"GITHUB_ACTIONS": "true", "GITHUB_OUTPUT": filepath.Join(t.TempDir(), "missing", "output")}
+	if err := vs.WriteCIOutput(".", vi, vi.Version()); err == nil {
+		t.Error("expected error for unwritable output file")
+	}
+}
@@ -0,0 +1,216 @@
+package gitsemver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigFileNames are the repository config file names LoadConfig looks for, in order.
+var ConfigFileNames = []string{".gitsemver.yaml", ".gitsemver.yml", ".gitsemver.toml"}
+
+// Config holds the settings from a repository config file.
+//
+// Only a flat subset of YAML and TOML is supported: one key per line with a
+// string, boolean or number value, or a list of those. Keys are lower case
+// and use '-' as separator, '_' is accepted as an alternative.
+type Config struct {
+	File   string              // path of the config file
+	Keys   []string            // keys in the order they appear in the file
+	Values map[string][]string // values by key, a single value for non-lists
+}
+
+// LoadConfig reads the first of ConfigFileNames found in the root of repo.
+// Returns a nil Config if there is none.
+func LoadConfig(repo string) (cfg *Config, err error) {
+	for _, name := range ConfigFileNames {
+		fileName := filepath.Join(repo, name)
+		var b []byte
+		if b, err = os.ReadFile(fileName); err == nil /* #nosec G304 */ {
+			return ParseConfig(fileName, string(b))
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+	}
+	return nil, nil
+}
+
+// ParseConfig parses the contents of a config file. The format is
+// TOML if fileName ends with ".toml", and YAML otherwise.
+func ParseConfig(fileName, text string) (cfg *Config, err error) {
+	cfg = &Config{File: fileName, Values: map[string][]string{}}
+	sep, isTOML := ":", strings.HasSuffix(fileName, ".toml")
+	if isTOML {
+		sep = "="
+	}
+	var listKey string // YAML key waiting for "- item" lines, or TOML key of an unterminated array
+	var pending string // TOML array text so far
+	for lineno, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if err == nil {
+			switch {
+			case isTOML && listKey != "":
+				if pending += " " + line; strings.HasSuffix(pending, "]") {
+					err = cfg.set(listKey, pending)
+					listKey = ""
+				}
+			case line == "":
+			case !isTOML && strings.HasPrefix(line, "-") && listKey != "":
+				var v string
+				if v, err = unquoteConfig(strings.TrimSpace(line[1:])); err == nil {
+					cfg.Values[listKey] = append(cfg.Values[listKey], v)
+				}
+			case isTOML && strings.HasPrefix(line, "["):
+				err = errors.New("tables are not supported")
+			default:
+				listKey = ""
+				key, value, ok := strings.Cut(line, sep)
+				key, value = strings.ReplaceAll(strings.TrimSpace(key), "_", "-"), strings.TrimSpace(value)
+				switch {
+				case !ok || !isConfigKey(key):
+					err = fmt.Errorf("expected key%svalue", sep)
+				case cfg.Values[key] != nil:
+					err = fmt.Errorf("duplicate key %q", key)
+				case !isTOML && value == "":
+					cfg.Keys = append(cfg.Keys, key)
+					cfg.Values[key] = []string{}
+					listKey = key
+				case isTOML && strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]"):
+					listKey, pending = key, value
+				default:
+					err = cfg.set(key, value)
+				}
+			}
+			if err != nil {
+				err = fmt.Errorf("%s:%d: %w", fileName, lineno+1, err)
+			}
+		}
+	}
+	if err == nil && isTOML && listKey != "" {
+		err = fmt.Errorf("%s: unterminated array for %q", fileName, listKey)
+	}
+	if err != nil {
+		cfg = nil
+	}
+	return
+}
+
+// Get returns the value for key and true if it's set and not a list.
+func (cfg *Config) Get(key string) (value string, ok bool) {
+	if values := cfg.Values[key]; len(values) == 1 {
+		value, ok = values[0], true
+	}
+	return
+}
+
+// set parses value as a scalar or an inline list and stores it under key.
+func (cfg *Config) set(key, value string) (err error) {
+	var values []string
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		values = []string{}
+		for _, item := range splitConfigList(value[1 : len(value)-1]) {
+			var v string
+			if v, err = unquoteConfig(item); err != nil {
+				return
+			}
+			values = append(values, v)
+		}
+	} else {
+		var v string
+		if v, err = unquoteConfig(value); err == nil {
+			values = []string{v}
+		}
+	}
+	if err == nil {
+		cfg.Keys = append(cfg.Keys, key)
+		cfg.Values[key] = values
+	}
+	return
+}
+
+func isConfigKey(key string) bool {
+	for _, ch := range key {
+		if !(ch == '-' || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9')) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// scanConfigQuotes calls fn for each byte of s that is outside quotes,
+// stopping when fn returns true.
+func scanConfigQuotes(s string, fn func(i int) bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		default:
+			if fn(i) {
+				return
+			}
+		}
+	}
+}
+
+// stripComment removes a '#' comment that is outside quotes and
+// at the start of line or preceded by whitespace.
+func stripComment(line string) (s string) {
+	s = line
+	scanConfigQuotes(line, func(i int) bool {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			s = line[:i]
+			return true
+		}
+		return false
+	})
+	return
+}
+
+// splitConfigList splits s on commas outside quotes, ignoring empty items.
+func splitConfigList(s string) (items []string) {
+	start := 0
+	add := func(end int) {
+		if item := strings.TrimSpace(s[start:end]); item != "" {
+			items = append(items, item)
+		}
+		start = end + 1
+	}
+	scanConfigQuotes(s, func(i int) bool {
+		if s[i] == ',' {
+			add(i)
+		}
+		return false
+	})
+	add(len(s))
+	return
+}
+
+// unquoteConfig returns s without surrounding quotes. Double quoted
+// strings may use Go escapes, single quoted strings are taken literally.
+func unquoteConfig(s string) (v string, err error) {
+	v = s
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			err = fmt.Errorf("unterminated string %s", s)
+		} else if s[0] == '"' {
+			v, err = strconv.Unquote(s)
+		} else {
+			v = s[1 : len(s)-1]
+		}
+	} else if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		err = fmt.Errorf("unsupported value %s", s)
+	}
+	return
+}
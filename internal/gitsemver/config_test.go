@@ -0,0 +1,107 @@
+package gitsemver_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func isEqualStrings(t *testing.T, a, b []string) {
+	t.Helper()
+	if !slices.Equal(a, b) {
+		t.Errorf("%q != %q", a, b)
+	}
+}
+
+func Test_ParseConfig_YAML(t *testing.T) {
+	cfg, err := gitsemver.ParseConfig(".gitsemver.yaml", `# policy
+prefix: "tools/cli/" # comment
+style: nextpatch
+gopackage: true
+release_branches:
+  - main
+  - 'release/*'
+bump-patch: [fix, "perf", ]
+out: 'a # b'
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqualStrings(t, []string{"prefix", "style", "gopackage", "release-branches", "bump-patch", "out"}, cfg.Keys)
+	isEqualStrings(t, []string{"main", "release/*"}, cfg.Values["release-branches"])
+	isEqualStrings(t, []string{"fix", "perf"}, cfg.Values["bump-patch"])
+	value, ok := cfg.Get("prefix")
+	isEqual(t, "tools/cli/", value)
+	isTrue(t, ok)
+	value, _ = cfg.Get("out")
+	isEqual(t, "a # b", value)
+	_, ok = cfg.Get("release-branches")
+	isTrue(t, !ok)
+}
+
+func Test_ParseConfig_TOML(t *testing.T) {
+	cfg, err := gitsemver.ParseConfig(".gitsemver.toml", `remote = "upstream"
+nofetch = true
+release-branches = [
+  "main", # trunk
+  "release/*",
+]
+bump_minor = ["feat"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqualStrings(t, []string{"remote", "nofetch", "release-branches", "bump-minor"}, cfg.Keys)
+	isEqualStrings(t, []string{"main", "release/*"}, cfg.Values["release-branches"])
+	value, _ := cfg.Get("nofetch")
+	isEqual(t, "true", value)
+}
+
+func Test_ParseConfig_Errors(t *testing.T) {
+	for name, text := range map[string]string{
+		".gitsemver.yaml": "prefix",
+		".gitsemver.yml":  "prefix: a\nprefix: b",
+		".gitsemver.toml": "[table]",
+		"x.toml":          "a = [\"b\"",
+		"y.toml":          "a = \"b",
+		"z.yaml":          "a: {b: c}",
+		"Bad Key.yaml":    "Bad Key: x",
+	} {
+		if _, err := gitsemver.ParseConfig(name, text); err == nil {
+			t.Errorf("%s: expected error for %q", name, text)
+		} else if !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: error %q doesn't mention the file", name, err)
+		}
+	}
+}
+
+func Test_LoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := gitsemver.LoadConfig(dir)
+	isEqual(t, err, nil)
+	isTrue(t, cfg == nil)
+
+	fileName := filepath.Join(dir, ".gitsemver.toml")
+	if err = os.WriteFile(fileName, []byte("style = \"metadata\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = gitsemver.LoadConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, fileName, cfg.File)
+	if !reflect.DeepEqual(cfg.Values, map[string][]string{"style": {"metadata"}}) {
+		t.Errorf("unexpected values %v", cfg.Values)
+	}
+
+	if err = os.Mkdir(filepath.Join(dir, ".gitsemver.yaml"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gitsemver.LoadConfig(dir); err == nil {
+		t.Error("expected error reading a directory")
+	}
+}
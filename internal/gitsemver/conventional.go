@@ -0,0 +1,80 @@
+package gitsemver
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Bump is a version increment level.
+type Bump int
+
+const (
+	BumpNone  Bump = iota // no release needed
+	BumpPatch             // increment the patch level
+	BumpMinor             // increment the minor level
+	BumpMajor             // increment the major level
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	}
+	return "none"
+}
+
+var (
+	reConventionalHeader = regexp.MustCompile(`^([A-Za-z]+)(\([^()\r\n]*\))?(!)?: \S`)
+	reBreakingFooter     = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: \S`)
+)
+
+// BumpRules maps lower case Conventional Commits types to the
+// version increment they imply.
+type BumpRules map[string]Bump
+
+// DefaultBumpRules are the rules from the Conventional Commits
+// specification, "feat" implies a minor bump and "fix" a patch bump.
+var DefaultBumpRules = BumpRules{"feat": BumpMinor, "fix": BumpPatch}
+
+// Bump returns the version increment implied by a single Conventional
+// Commits message. A "!" after the type or scope, or a "BREAKING CHANGE:"
+// footer, always implies a major bump. Otherwise the type is looked up
+// in rules, and anything not found implies no bump.
+func (rules BumpRules) Bump(message string) (bump Bump) {
+	header, body, _ := strings.Cut(message, "\n")
+	if m := reConventionalHeader.FindStringSubmatch(strings.TrimSpace(header)); m != nil {
+		bump = rules[strings.ToLower(m[1])]
+		if m[3] != "" {
+			bump = BumpMajor
+		}
+	}
+	if reBreakingFooter.MatchString(body) {
+		bump = BumpMajor
+	}
+	return
+}
+
+// Bumps returns the highest version increment implied
+// by the given Conventional Commits messages.
+func (rules BumpRules) Bumps(messages []string) (bump Bump) {
+	for _, msg := range messages {
+		bump = max(bump, rules.Bump(msg))
+	}
+	return
+}
+
+// ConventionalBump returns the version increment implied by a single
+// Conventional Commits message using DefaultBumpRules.
+func ConventionalBump(message string) Bump {
+	return DefaultBumpRules.Bump(message)
+}
+
+// ConventionalBumps returns the highest version increment implied
+// by the given Conventional Commits messages using DefaultBumpRules.
+func ConventionalBumps(messages []string) Bump {
+	return DefaultBumpRules.Bumps(messages)
+}
@@ -0,0 +1,69 @@
+package gitsemver_test
+
+import (
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_ConventionalBump(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want gitsemver.Bump
+	}{
+		{msg: "fix: handle empty tags", want: gitsemver.BumpPatch},
+		{msg: "fix(gitter): handle empty tags", want: gitsemver.BumpPatch},
+		{msg: "feat: add -incauto", want: gitsemver.BumpMinor},
+		{msg: "Feat(cli): add -incauto", want: gitsemver.BumpMinor},
+		{msg: "feat!: drop -incpatch", want: gitsemver.BumpMajor},
+		{msg: "refactor(core)!: rename Gitter", want: gitsemver.BumpMajor},
+		{msg: "fix: x\n\nsome body\n\nBREAKING CHANGE: config format changed", want: gitsemver.BumpMajor},
+		{msg: "chore: x\n\nBREAKING-CHANGE: removed flag", want: gitsemver.BumpMajor},
+		{msg: "chore: update deps", want: gitsemver.BumpNone},
+		{msg: "docs: BREAKING CHANGE: is only a footer in the body", want: gitsemver.BumpNone},
+		{msg: "feat:missing space", want: gitsemver.BumpNone},
+		{msg: "Merge branch 'feature'", want: gitsemver.BumpNone},
+		{msg: "", want: gitsemver.BumpNone},
+	}
+	for _, tt := range tests {
+		if got := gitsemver.ConventionalBump(tt.msg); got != tt.want {
+			t.Errorf("ConventionalBump(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func Test_ConventionalBumps(t *testing.T) {
+	isEqual(t, gitsemver.BumpNone, gitsemver.ConventionalBumps(nil))
+	isEqual(t, gitsemver.BumpMinor, gitsemver.ConventionalBumps([]string{"fix: a", "feat: b", "chore: c"}))
+	isEqual(t, gitsemver.BumpMajor, gitsemver.ConventionalBumps([]string{"feat!: a", "fix: b"}))
+}
+
+func Test_BumpRules(t *testing.T) {
+	rules := gitsemver.BumpRules{"feat": gitsemver.BumpMinor, "fix": gitsemver.BumpPatch, "perf": gitsemver.BumpPatch}
+	isEqual(t, gitsemver.BumpPatch, rules.Bump("perf: faster"))
+	isEqual(t, gitsemver.BumpNone, gitsemver.ConventionalBump("perf: faster"))
+	isEqual(t, gitsemver.BumpMajor, rules.Bump("chore!: drop support"))
+	isEqual(t, gitsemver.BumpMinor, rules.Bumps([]string{"perf: a", "Feat: b"}))
+	isEqual(t, gitsemver.BumpMajor, gitsemver.BumpRules{}.Bump("docs: a\n\nBREAKING CHANGE: b"))
+}
+
+func Test_Bump_String(t *testing.T) {
+	isEqual(t, "none", gitsemver.BumpNone.String())
+	isEqual(t, "patch", gitsemver.BumpPatch.String())
+	isEqual(t, "minor", gitsemver.BumpMinor.String())
+	isEqual(t, "major", gitsemver.BumpMajor.String())
+}
+
+func Test_VersionInfo_Inc(t *testing.T) {
+	for bump, want := range map[gitsemver.Bump]string{
+		gitsemver.BumpNone:  "v1.2.3",
+		gitsemver.BumpPatch: "v1.2.4",
+		gitsemver.BumpMinor: "v1.3.0",
+		gitsemver.BumpMajor: "v2.0.0",
+	} {
+		vi := &gitsemver.VersionInfo{Tag: "v1.2.3"}
+		if got := vi.Inc(bump); got != want {
+			t.Errorf("Inc(%v) = %q, want %q", bump, got, want)
+		}
+	}
+}
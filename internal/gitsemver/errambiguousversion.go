@@ -0,0 +1,35 @@
+package gitsemver
+
+import (
+	"fmt"
+	"strings"
+)
+
+type errAmbiguousVersion struct {
+	version string
+	matches []VersionMatch
+}
+
+// ErrAmbiguousVersion classifies errors where a version
+// resolves to more than one commit.
+var ErrAmbiguousVersion = &errAmbiguousVersion{}
+
+func NewErrAmbiguousVersion(version string, matches []VersionMatch) error {
+	return &errAmbiguousVersion{
+		version: version,
+		matches: matches,
+	}
+}
+
+func (err *errAmbiguousVersion) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "version %q is ambiguous, it matches %d commits:", err.version, len(err.matches))
+	for _, m := range err.matches {
+		fmt.Fprintf(&sb, "\n  %s tag %q branch %q", m.Commit, m.Tag, m.Branch)
+	}
+	return sb.String()
+}
+
+func (err *errAmbiguousVersion) Is(other error) bool {
+	return other == ErrAmbiguousVersion
+}
@@ -0,0 +1,22 @@
+package gitsemver
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_errAmbiguousVersion_Error(t *testing.T) {
+	err := NewErrAmbiguousVersion("v1.0.0-3", []VersionMatch{
+		{Commit: "commit-1", Tag: "v1.0.0", Branch: "main"},
+		{Commit: "commit-2", Tag: "v1.0.0", Branch: "feature"},
+	})
+	want := "version \"v1.0.0-3\" is ambiguous, it matches 2 commits:\n" +
+		"  commit-1 tag \"v1.0.0\" branch \"main\"\n" +
+		"  commit-2 tag \"v1.0.0\" branch \"feature\""
+	if got := err.Error(); got != want {
+		t.Errorf("errAmbiguousVersion.Error() = \n got %q\nwant %q\n", got, want)
+	}
+	if !errors.Is(err, ErrAmbiguousVersion) {
+		t.Error("not ErrAmbiguousVersion")
+	}
+}
@@ -0,0 +1,40 @@
+package gitsemver
+
+import (
+	"fmt"
+
+	xmodsemver "golang.org/x/mod/semver"
+)
+
+type errModuleMajor struct {
+	modulePath string
+	tag        string
+}
+
+// ErrModuleMajor classifies errors where the module path in go.mod
+// does not carry the major version suffix required by a tag.
+var ErrModuleMajor = &errModuleMajor{}
+
+func NewErrModuleMajor(modulePath, tag string) error {
+	return &errModuleMajor{
+		modulePath: modulePath,
+		tag:        tag,
+	}
+}
+
+func (err *errModuleMajor) Error() string {
+	want := "no major version suffix"
+	if canonical, ok := canonicalSemverTag(err.tag); ok {
+		if major := xmodsemver.Major(canonical); major != "v0" && major != "v1" {
+			want = fmt.Sprintf("%q suffix", "/"+major)
+		}
+	}
+	return fmt.Sprintf(
+		"module path %q in go.mod does not match tag %q: want %s",
+		err.modulePath, err.tag, want,
+	)
+}
+
+func (err *errModuleMajor) Is(other error) bool {
+	return other == ErrModuleMajor
+}
@@ -0,0 +1,39 @@
+package gitsemver
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_errModuleMajor_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		modulePath string
+		tag        string
+		want       string
+	}{
+		{
+			name:       "missing suffix",
+			modulePath: "example.com/foo",
+			tag:        "v2.0.0",
+			want:       "module path \"example.com/foo\" in go.mod does not match tag \"v2.0.0\": want \"/v2\" suffix",
+		},
+		{
+			name:       "unexpected suffix",
+			modulePath: "example.com/foo/v2",
+			tag:        "v1.0.0",
+			want:       "module path \"example.com/foo/v2\" in go.mod does not match tag \"v1.0.0\": want no major version suffix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewErrModuleMajor(tt.modulePath, tt.tag)
+			if got := err.Error(); got != tt.want {
+				t.Errorf("errModuleMajor.Error() = \n got %q\nwant %q\n", got, tt.want)
+			}
+			if !errors.Is(err, ErrModuleMajor) {
+				t.Error("not ErrModuleMajor")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package gitsemver
+
+import (
+	"fmt"
+	"strings"
+)
+
+type errPushRejected struct {
+	remote string
+	tag    string
+	err    error
+}
+
+// ErrPushRejected classifies errors where the remote rejected a push because
+// the tag already exists there or the branch moved on, typically because
+// another job pushed first.
+var ErrPushRejected = &errPushRejected{}
+
+func NewErrPushRejected(remote, tag string, err error) error {
+	return &errPushRejected{
+		remote: remote,
+		tag:    tag,
+		err:    err,
+	}
+}
+
+func (err *errPushRejected) Error() string {
+	return fmt.Sprintf("push of %q to %q was rejected: %v", err.tag, err.remote, err.err)
+}
+
+func (err *errPushRejected) Is(other error) bool {
+	return other == ErrPushRejected
+}
+
+func (err *errPushRejected) Unwrap() error {
+	return err.err
+}
+
+// isPushRejected returns true if the stderr of "git push" shows a ref that
+// was rejected since the remote has a tag or commits we don't. Rejections
+// by hooks or server policy are not included, as retrying won't help.
+func isPushRejected(stderr string) bool {
+	for _, reason := range []string{"(already exists)", "(non-fast-forward)", "(fetch first)"} {
+		if strings.Contains(stderr, reason) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package gitsemver
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_errPushRejected_Error(t *testing.T) {
+	inner := errors.New("exit status 1")
+	err := NewErrPushRejected("origin", "v1.0.1", inner)
+	want := "push of \"v1.0.1\" to \"origin\" was rejected: exit status 1"
+	if got := err.Error(); got != want {
+		t.Errorf("errPushRejected.Error() = \n got %q\nwant %q\n", got, want)
+	}
+	if !errors.Is(err, ErrPushRejected) {
+		t.Error("not ErrPushRejected")
+	}
+	if !errors.Is(err, inner) {
+		t.Error("does not unwrap")
+	}
+}
+
+func Test_isPushRejected(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		{" ! [rejected]        v1.0.1 -> v1.0.1 (already exists)", true},
+		{" ! [rejected]        HEAD -> main (non-fast-forward)", true},
+		{" ! [rejected]        HEAD -> main (fetch first)", true},
+		{" ! [remote rejected] v1.0.1 -> v1.0.1 (pre-receive hook declined)", false},
+		{"fatal: 'origin' does not appear to be a git repository", false},
+	}
+	for _, tt := range tests {
+		if got := isPushRejected(tt.stderr); got != tt.want {
+			t.Errorf("isPushRejected(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
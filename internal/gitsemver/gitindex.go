@@ -0,0 +1,217 @@
+package gitsemver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// gitIndexEntry is an entry in the index file.
+type gitIndexEntry struct {
+	path      string
+	mode      uint32
+	hash      string
+	size      uint32
+	mtimeSec  uint32
+	mtimeNsec uint32
+	stage     int
+	skip      bool // assume-valid or skip-worktree, so the worktree file isn't checked
+	added     bool // intent-to-add
+}
+
+const (
+	indexFlagAssumeValid  = 0x8000
+	indexFlagExtended     = 0x4000
+	indexFlagSkipWorktree = 0x4000 // in the extended flags
+	indexFlagIntentToAdd  = 0x2000 // in the extended flags
+)
+
+// readIndex reads the entries in the index file and its modification time.
+// Split and sparse indexes return errUnsupportedRepo.
+func (r *gitRepo) readIndex() (entries []gitIndexEntry, mtime time.Time, err error) {
+	fileName := filepath.Join(r.gitDir, "index")
+	var b []byte
+	if b, err = os.ReadFile(fileName); err != nil /* #nosec G304 */ {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	var fi os.FileInfo
+	if fi, err = os.Stat(fileName); err != nil {
+		return
+	}
+	mtime = fi.ModTime()
+	errCorrupt := fmt.Errorf("%s: corrupt index", r.gitDir)
+	if len(b) < 12 || string(b[:4]) != "DIRC" {
+		return nil, mtime, errCorrupt
+	}
+	version := binary.BigEndian.Uint32(b[4:])
+	if version < 2 || version > 4 {
+		return nil, mtime, errUnsupportedRepo
+	}
+	count := int(binary.BigEndian.Uint32(b[8:]))
+	pos := 12
+	prevPath := ""
+	fixed := 40 + r.hashLen + 2
+	for i := 0; i < count; i++ {
+		if pos+fixed > len(b) {
+			return nil, mtime, errCorrupt
+		}
+		e := gitIndexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(b[pos+8:]),
+			mtimeNsec: binary.BigEndian.Uint32(b[pos+12:]),
+			mode:      binary.BigEndian.Uint32(b[pos+24:]),
+			size:      binary.BigEndian.Uint32(b[pos+36:]),
+			hash:      hex.EncodeToString(b[pos+40 : pos+40+r.hashLen]),
+		}
+		flags := binary.BigEndian.Uint16(b[pos+40+r.hashLen:])
+		e.stage = int(flags>>12) & 3
+		e.skip = flags&indexFlagAssumeValid != 0
+		namePos := pos + fixed
+		if flags&indexFlagExtended != 0 && version >= 3 {
+			if namePos+2 > len(b) {
+				return nil, mtime, errCorrupt
+			}
+			extended := binary.BigEndian.Uint16(b[namePos:])
+			e.skip = e.skip || extended&indexFlagSkipWorktree != 0
+			e.added = extended&indexFlagIntentToAdd != 0
+			namePos += 2
+		}
+		if version == 4 {
+			// the path is the previous path with some bytes removed and a suffix added
+			strip, n := 0, 0
+			for n < len(b)-namePos {
+				c := b[namePos+n]
+				n++
+				strip = strip<<7 | int(c&0x7f)
+				if c&0x80 == 0 {
+					break
+				}
+				strip++
+			}
+			nul := bytes.IndexByte(b[namePos+n:], 0)
+			if nul < 0 || strip > len(prevPath) {
+				return nil, mtime, errCorrupt
+			}
+			e.path = prevPath[:len(prevPath)-strip] + string(b[namePos+n:namePos+n+nul])
+			pos = namePos + n + nul + 1
+		} else {
+			nul := bytes.IndexByte(b[namePos:], 0)
+			if nul < 0 {
+				return nil, mtime, errCorrupt
+			}
+			e.path = string(b[namePos : namePos+nul])
+			pos += (namePos - pos + nul + 8) &^ 7
+		}
+		prevPath = e.path
+		entries = append(entries, e)
+	}
+	// extensions we can't ignore change the meaning of the entries
+	for pos+8 <= len(b)-r.hashLen {
+		sig := string(b[pos : pos+4])
+		if sig == "link" || sig == "sdir" {
+			return nil, mtime, errUnsupportedRepo
+		}
+		pos += 8 + int(binary.BigEndian.Uint32(b[pos+4:]))
+	}
+	return
+}
+
+// flattenTree adds the non-tree entries in tree to files, keyed by their path.
+func (r *gitRepo) flattenTree(tree, dir string, files map[string]gitTreeEntry) (err error) {
+	var entries []gitTreeEntry
+	if entries, err = r.tree(tree); err == nil {
+		for _, e := range entries {
+			if e.mode == "40000" {
+				if err = r.flattenTree(e.hash, dir+e.name+"/", files); err != nil {
+					return
+				}
+			} else {
+				files[dir+e.name] = e
+			}
+		}
+	}
+	return
+}
+
+// errWorktreeChanged means that the stat data of a worktree file don't show
+// it to be unchanged, and comparing its contents is left to git, which applies
+// the attributes and filters like autocrlf.
+var errWorktreeChanged = fmt.Errorf("%w: worktree file may have changed", errUnsupportedRepo)
+
+// cleanStatus returns true if neither the index nor the tracked files in the
+// worktree differ from HEAD. Untracked files are ignored. It returns
+// errWorktreeChanged if a file's stat data differ from the index, or if the
+// file was modified too close to the index being written to tell.
+func (r *gitRepo) cleanStatus(worktree string) (yes bool, err error) {
+	var head string
+	var entries []gitIndexEntry
+	var indexTime time.Time
+	if head, err = r.resolve("HEAD"); err == nil {
+		if entries, indexTime, err = r.readIndex(); err == nil {
+			files := map[string]gitTreeEntry{}
+			if head != "" {
+				var c *gitCommit
+				if c, err = r.commit(head); err == nil {
+					err = r.flattenTree(c.tree, "", files)
+				}
+			}
+			if err == nil {
+				if len(entries) != len(files) {
+					return false, nil
+				}
+				for _, e := range entries {
+					if f, ok := files[e.path]; !ok || e.stage != 0 || e.added || f.hash != e.hash || f.mode != strconv.FormatUint(uint64(e.mode), 8) {
+						return false, nil
+					}
+				}
+				for _, e := range entries {
+					if yes, err = r.worktreeClean(worktree, e, indexTime); !yes || err != nil {
+						return
+					}
+				}
+				yes = true
+			}
+		}
+	}
+	return
+}
+
+// worktreeClean returns true if the worktree file matches the index entry.
+func (r *gitRepo) worktreeClean(worktree string, e gitIndexEntry, indexTime time.Time) (yes bool, err error) {
+	const modeTypeMask, modeSymlink, modeGitlink = 0o170000, 0o120000, 0o160000
+	if e.skip || e.mode&modeTypeMask == modeGitlink {
+		return true, nil
+	}
+	fileName := filepath.Join(worktree, filepath.FromSlash(e.path))
+	var fi os.FileInfo
+	if fi, err = os.Lstat(fileName); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	isLink := fi.Mode()&fs.ModeSymlink != 0
+	if isLink != (e.mode&modeTypeMask == modeSymlink) || (!isLink && !fi.Mode().IsRegular()) {
+		return false, nil
+	}
+	if !isLink && r.config["core.filemode"] != "false" && (fi.Mode()&0o111 != 0) != (e.mode&0o111 != 0) {
+		return false, nil
+	}
+	mtime := fi.ModTime()
+	entryTime := time.Unix(int64(e.mtimeSec), int64(e.mtimeNsec))
+	// an entry no older than the index is racy, the file may have changed
+	// within the same timestamp after it was added
+	if uint32(fi.Size()) != e.size || uint32(mtime.Unix()) != e.mtimeSec || uint32(mtime.Nanosecond()) != e.mtimeNsec || !entryTime.Before(indexTime) { // #nosec G115
+		return false, fmt.Errorf("%w: %s", errWorktreeChanged, e.path)
+	}
+	return true, nil
+}
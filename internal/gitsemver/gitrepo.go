@@ -0,0 +1,715 @@
+package gitsemver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errUnsupportedRepo is returned by openGitRepo for repositories that
+// use features the internal reader doesn't support, like reftables.
+var errUnsupportedRepo = errors.New("repository format not supported by the internal reader")
+
+// errUnsupportedRev is returned for revisions that aren't a full object name
+// or ref, either because they don't exist or use syntax like "HEAD~1".
+var errUnsupportedRev = errors.New("revision not supported by the internal reader")
+
+// gitRepo reads refs and objects directly from a git directory.
+type gitRepo struct {
+	gitDir    string            // git directory with HEAD and index
+	commonDir string            // git directory with objects and refs, differs from gitDir for worktrees
+	config    map[string]string // "section.key" and "section.subsection.key" values from the config file
+	hashLen   int               // length of object names in bytes, 20 for SHA-1 or 32 for SHA-256
+	objDirs   []string          // object directories, our own followed by alternates
+	packs     []*gitPack        // pack files, nil until needed
+	commits   map[string]*gitCommit
+	shallow   map[string]bool // commits listed in the shallow file, their parents are missing
+}
+
+// gitCommit holds the parts of a commit object we need.
+type gitCommit struct {
+	tree    string
+	parents []string
+	when    time.Time // committer time
+	message string
+}
+
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objTypeNames = map[string]int{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+
+// openGitRepo opens the git directory of the worktree in repo.
+func openGitRepo(repo string) (r *gitRepo, err error) {
+	r = &gitRepo{gitDir: filepath.Join(repo, ".git"), hashLen: 20, commits: map[string]*gitCommit{}}
+	var fi os.FileInfo
+	if fi, err = os.Stat(r.gitDir); err == nil && !fi.IsDir() {
+		// a worktree or submodule, ".git" is a file with "gitdir: <path>"
+		var b []byte
+		if b, err = os.ReadFile(r.gitDir); err == nil /* #nosec G304 */ {
+			dir, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir:")
+			if dir = strings.TrimSpace(dir); !ok || dir == "" {
+				err = fmt.Errorf("%s: missing gitdir", r.gitDir)
+			} else if !filepath.IsAbs(dir) {
+				dir = filepath.Join(repo, dir)
+			}
+			r.gitDir = dir
+		}
+	}
+	if err == nil {
+		r.commonDir = r.gitDir
+		if b, e := os.ReadFile(filepath.Join(r.gitDir, "commondir")); e == nil /* #nosec G304 */ {
+			if r.commonDir = strings.TrimSpace(string(b)); !filepath.IsAbs(r.commonDir) {
+				r.commonDir = filepath.Join(r.gitDir, r.commonDir)
+			}
+		}
+		if r.config, err = readGitConfig(filepath.Join(r.commonDir, "config")); err == nil {
+			if strings.EqualFold(r.config["extensions.objectformat"], "sha256") {
+				r.hashLen = 32
+			}
+			if r.config["extensions.refstorage"] != "" && !strings.EqualFold(r.config["extensions.refstorage"], "files") {
+				err = errUnsupportedRepo
+			} else if _, e := os.Stat(filepath.Join(r.commonDir, "reftable")); e == nil {
+				err = errUnsupportedRepo
+			}
+		}
+	}
+	if err == nil {
+		// in a shallow clone git treats these commits as having no parents
+		if b, e := os.ReadFile(filepath.Join(r.commonDir, "shallow")); e == nil /* #nosec G304 */ {
+			r.shallow = map[string]bool{}
+			for _, hash := range strings.Fields(string(b)) {
+				r.shallow[hash] = true
+			}
+		}
+		objDir := filepath.Join(r.commonDir, "objects")
+		r.objDirs = append(r.objDirs, objDir)
+		if b, e := os.ReadFile(filepath.Join(objDir, "info", "alternates")); e == nil /* #nosec G304 */ {
+			for _, alt := range strings.Split(string(b), "\n") {
+				if alt = strings.TrimSpace(alt); alt != "" && !strings.HasPrefix(alt, "#") {
+					if !filepath.IsAbs(alt) {
+						alt = filepath.Join(objDir, alt)
+					}
+					r.objDirs = append(r.objDirs, alt)
+				}
+			}
+		}
+	}
+	if err != nil {
+		r = nil
+	}
+	return
+}
+
+// readGitConfig reads a git config file into a map of lower case "section.key" or
+// "section.subsection.key" names and their values. Includes are not followed.
+func readGitConfig(fileName string) (config map[string]string, err error) {
+	config = map[string]string{}
+	var b []byte
+	if b, err = os.ReadFile(fileName); err == nil /* #nosec G304 */ {
+		section := ""
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "[") {
+				if end := strings.IndexByte(line, ']'); end > 0 {
+					name, sub, _ := strings.Cut(line[1:end], " ")
+					section = strings.ToLower(name)
+					if sub = strings.Trim(strings.TrimSpace(sub), `"`); sub != "" {
+						section += "." + sub
+					}
+				}
+			} else if line != "" && line[0] != '#' && line[0] != ';' {
+				key, value, ok := strings.Cut(line, "=")
+				if !ok {
+					value = "true"
+				}
+				config[section+"."+strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (r *gitRepo) isHash(s string) bool {
+	if len(s) != r.hashLen*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// packedRefs returns the refs in the packed-refs file.
+func (r *gitRepo) packedRefs() (refs map[string]string, err error) {
+	refs = map[string]string{}
+	var b []byte
+	if b, err = os.ReadFile(filepath.Join(r.commonDir, "packed-refs")); err == nil /* #nosec G304 */ {
+		for _, line := range strings.Split(string(b), "\n") {
+			if hash, name, ok := strings.Cut(strings.TrimSpace(line), " "); ok && r.isHash(hash) {
+				refs[name] = hash
+			}
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// refDir returns the directory holding the loose ref name.
+// Per-worktree refs are in gitDir, the others in commonDir.
+func (r *gitRepo) refDir(name string) string {
+	if !strings.HasPrefix(name, "refs/") || strings.HasPrefix(name, "refs/bisect/") || strings.HasPrefix(name, "refs/worktree/") {
+		return r.gitDir
+	}
+	return r.commonDir
+}
+
+// readRef returns the object name the ref points to, following symbolic refs.
+// Returns an empty string if the ref doesn't exist.
+func (r *gitRepo) readRef(name string) (hash string, err error) {
+	for depth := 0; depth < 8; depth++ {
+		var b []byte
+		if b, err = os.ReadFile(filepath.Join(r.refDir(name), filepath.FromSlash(name))); err == nil /* #nosec G304 */ {
+			s := strings.TrimSpace(string(b))
+			if target, ok := strings.CutPrefix(s, "ref:"); ok {
+				name = strings.TrimSpace(target)
+				continue
+			}
+			if !r.isHash(s) {
+				err = fmt.Errorf("ref %s: invalid contents %q", name, s)
+			}
+			return s, err
+		}
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.EISDIR) {
+			var packed map[string]string
+			if packed, err = r.packedRefs(); err == nil {
+				hash = packed[name]
+			}
+		}
+		return
+	}
+	return "", fmt.Errorf("ref %s: too many levels of symbolic refs", name)
+}
+
+// symbolicRef returns the ref that the symbolic ref name points to,
+// or an empty string if it's not a symbolic ref.
+func (r *gitRepo) symbolicRef(name string) (target string, err error) {
+	var b []byte
+	if b, err = os.ReadFile(filepath.Join(r.refDir(name), filepath.FromSlash(name))); err == nil /* #nosec G304 */ {
+		if s, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "ref:"); ok {
+			target = strings.TrimSpace(s)
+		}
+	}
+	return
+}
+
+// listRefs returns the refs starting with prefix, like "refs/tags/", and the objects they point to.
+func (r *gitRepo) listRefs(prefix string) (refs map[string]string, err error) {
+	var packed map[string]string
+	if packed, err = r.packedRefs(); err == nil {
+		refs = map[string]string{}
+		for name, hash := range packed {
+			if strings.HasPrefix(name, prefix) {
+				refs[name] = hash
+			}
+		}
+		root := filepath.Join(r.refDir(prefix), filepath.FromSlash(prefix))
+		err = filepath.WalkDir(root, func(fpath string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && !strings.HasSuffix(fpath, ".lock") {
+				var rel string
+				if rel, err = filepath.Rel(root, fpath); err == nil {
+					name := prefix + filepath.ToSlash(rel)
+					var hash string
+					if hash, err = r.readRef(name); err == nil && hash != "" {
+						refs[name] = hash
+					}
+				}
+			}
+			return err
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	return
+}
+
+// resolve returns the object name for rev, which is either a full object
+// name or a ref name that git would accept, like "HEAD", "v1.2.3" or
+// "tags/v1.2.3". Returns an empty string if it can't be found.
+func (r *gitRepo) resolve(rev string) (hash string, err error) {
+	if r.isHash(rev) {
+		return strings.ToLower(rev), nil
+	}
+	for _, name := range []string{rev, "refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev, "refs/remotes/" + rev, "refs/remotes/" + rev + "/HEAD"} {
+		if name == rev && name != "HEAD" && !strings.HasPrefix(name, "refs/") {
+			continue
+		}
+		if hash, err = r.readRef(name); err != nil || hash != "" {
+			return
+		}
+	}
+	return
+}
+
+// mustResolve is like resolve, but returns errUnsupportedRev if rev can't be resolved.
+func (r *gitRepo) mustResolve(rev string) (hash string, err error) {
+	if hash, err = r.resolve(rev); err == nil && hash == "" {
+		err = fmt.Errorf("%w: %q", errUnsupportedRev, rev)
+	}
+	return
+}
+
+// readObject returns the type and contents of the object.
+func (r *gitRepo) readObject(hash string) (kind int, data []byte, err error) {
+	var bin []byte
+	if bin, err = hex.DecodeString(hash); err == nil && len(bin) == r.hashLen {
+		for _, objDir := range r.objDirs {
+			if kind, data, err = readLooseObject(filepath.Join(objDir, hash[:2], hash[2:])); !errors.Is(err, fs.ErrNotExist) {
+				return
+			}
+		}
+		for rescan := 0; rescan < 2; rescan++ {
+			if r.packs == nil || rescan > 0 {
+				if err = r.loadPacks(); err != nil {
+					return
+				}
+			}
+			for _, p := range r.packs {
+				if offset, ok := p.find(bin); ok {
+					return p.readAt(r, offset)
+				}
+			}
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("object %q not found", hash)
+	}
+	return
+}
+
+func readLooseObject(fileName string) (kind int, data []byte, err error) {
+	var f *os.File
+	if f, err = os.Open(fileName); err == nil /* #nosec G304 */ {
+		defer f.Close()
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bufio.NewReader(f)); err == nil {
+			defer zr.Close()
+			if data, err = io.ReadAll(zr); err == nil {
+				err = fmt.Errorf("%s: invalid object header", fileName)
+				if header, body, ok := bytes.Cut(data, []byte{0}); ok {
+					typeName, size, _ := strings.Cut(string(header), " ")
+					if n, e := strconv.Atoi(size); e == nil && n == len(body) && objTypeNames[typeName] != 0 {
+						kind, data, err = objTypeNames[typeName], body, nil
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func (r *gitRepo) loadPacks() (err error) {
+	for _, p := range r.packs {
+		p.close()
+	}
+	r.packs = []*gitPack{}
+	for _, objDir := range r.objDirs {
+		var names []string
+		if names, err = filepath.Glob(filepath.Join(objDir, "pack", "*.idx")); err == nil {
+			sort.Strings(names)
+			for _, name := range names {
+				var p *gitPack
+				if p, err = openGitPack(name, r.hashLen); err != nil {
+					return
+				}
+				r.packs = append(r.packs, p)
+			}
+		}
+	}
+	return
+}
+
+// close closes the pack files.
+func (r *gitRepo) close() {
+	for _, p := range r.packs {
+		p.close()
+	}
+	r.packs = nil
+}
+
+// peel follows tag objects until it finds a commit and returns its name.
+func (r *gitRepo) peel(hash string) (commit string, err error) {
+	for depth := 0; depth < 16; depth++ {
+		var kind int
+		var data []byte
+		if kind, data, err = r.readObject(hash); err != nil {
+			return
+		}
+		switch kind {
+		case objCommit:
+			return hash, nil
+		case objTag:
+			hash = ""
+			for _, line := range strings.Split(string(data), "\n") {
+				if line == "" {
+					break
+				}
+				if target, ok := strings.CutPrefix(line, "object "); ok {
+					hash = target
+				}
+			}
+			if hash == "" {
+				return "", fmt.Errorf("tag object has no target")
+			}
+		default:
+			return "", fmt.Errorf("%s is not a commit", hash)
+		}
+	}
+	return "", fmt.Errorf("too many levels of tags")
+}
+
+// commit returns the parsed commit object.
+func (r *gitRepo) commit(hash string) (c *gitCommit, err error) {
+	if c = r.commits[hash]; c == nil {
+		var kind int
+		var data []byte
+		if kind, data, err = r.readObject(hash); err == nil {
+			if kind == objCommit {
+				if c = parseCommit(data); r.shallow[hash] {
+					c.parents = nil
+				}
+				r.commits[hash] = c
+			} else {
+				err = fmt.Errorf("%s is not a commit", hash)
+			}
+		}
+	}
+	return
+}
+
+func parseCommit(data []byte) (c *gitCommit) {
+	c = &gitCommit{}
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	c.message = message
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.tree = value
+		case "parent":
+			c.parents = append(c.parents, value)
+		case "committer":
+			c.when = parseSignatureTime(value)
+		}
+	}
+	return
+}
+
+// commitMessages returns the non-empty messages of the commits, newest first.
+func commitMessages(commits []*gitCommit) (messages []string) {
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].when.After(commits[j].when) })
+	for _, c := range commits {
+		if msg := strings.TrimSpace(c.message); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	return
+}
+
+// parseSignatureTime returns the time from a "Name <email> 1234567890 +0100" signature.
+func parseSignatureTime(sig string) (when time.Time) {
+	if idx := strings.LastIndexByte(sig, '>'); idx >= 0 {
+		if fields := strings.Fields(sig[idx+1:]); len(fields) == 2 {
+			if secs, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				when = time.Unix(secs, 0).UTC()
+				if tz, err := strconv.Atoi(fields[1]); err == nil && len(fields[1]) == 5 && tz != 0 {
+					offset := (tz/100*60 + tz%100) * 60
+					when = when.In(time.FixedZone("", offset))
+				}
+			}
+		}
+	}
+	return
+}
+
+// treeEntry returns the mode and object name of the entry for the slash separated
+// path in the tree, or empty strings if it doesn't exist.
+func (r *gitRepo) treeEntry(tree, path string) (mode, hash string, err error) {
+	hash, mode = tree, "40000"
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if mode != "40000" {
+			return "", "", nil
+		}
+		var entries []gitTreeEntry
+		if entries, err = r.tree(hash); err != nil {
+			return
+		}
+		mode, hash = "", ""
+		for _, e := range entries {
+			if e.name == name {
+				mode, hash = e.mode, e.hash
+				break
+			}
+		}
+		if hash == "" {
+			break
+		}
+	}
+	return
+}
+
+type gitTreeEntry struct {
+	mode string
+	name string
+	hash string
+}
+
+func (r *gitRepo) tree(hash string) (entries []gitTreeEntry, err error) {
+	var kind int
+	var data []byte
+	if kind, data, err = r.readObject(hash); err == nil {
+		if kind != objTree {
+			return nil, fmt.Errorf("%s is not a tree", hash)
+		}
+		for len(data) > 0 {
+			sp := bytes.IndexByte(data, ' ')
+			nul := bytes.IndexByte(data, 0)
+			if sp < 0 || nul < sp || len(data) < nul+1+r.hashLen {
+				return nil, fmt.Errorf("tree %s is corrupt", hash)
+			}
+			entries = append(entries, gitTreeEntry{
+				mode: string(data[:sp]),
+				name: string(data[sp+1 : nul]),
+				hash: hex.EncodeToString(data[nul+1 : nul+1+r.hashLen]),
+			})
+			data = data[nul+1+r.hashLen:]
+		}
+	}
+	return
+}
+
+// gitPack reads objects from a pack file using its version 2 index.
+type gitPack struct {
+	f       *os.File
+	idx     []byte
+	count   int
+	hashLen int
+	cache   map[int64]packObject
+}
+
+type packObject struct {
+	kind int
+	data []byte
+}
+
+const packCacheSize = 512
+
+func openGitPack(idxName string, hashLen int) (p *gitPack, err error) {
+	var idx []byte
+	if idx, err = os.ReadFile(idxName); err == nil /* #nosec G304 */ {
+		err = fmt.Errorf("%s: unsupported pack index", idxName)
+		if len(idx) >= 8+256*4 && bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) && binary.BigEndian.Uint32(idx[4:]) == 2 {
+			count := int(binary.BigEndian.Uint32(idx[8+255*4:]))
+			if len(idx) >= 8+256*4+count*(hashLen+8) {
+				var f *os.File
+				if f, err = os.Open(strings.TrimSuffix(idxName, ".idx") + ".pack"); err == nil /* #nosec G304 */ {
+					p = &gitPack{f: f, idx: idx, count: count, hashLen: hashLen, cache: map[int64]packObject{}}
+				}
+			}
+		}
+	}
+	return
+}
+
+func (p *gitPack) close() {
+	_ = p.f.Close()
+}
+
+// find returns the offset in the pack of the object with the binary name.
+func (p *gitPack) find(name []byte) (offset int64, ok bool) {
+	const fanout = 8
+	names := fanout + 256*4
+	lo := 0
+	if name[0] > 0 {
+		lo = int(binary.BigEndian.Uint32(p.idx[fanout+(int(name[0])-1)*4:]))
+	}
+	hi := int(binary.BigEndian.Uint32(p.idx[fanout+int(name[0])*4:]))
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.idx[names+(lo+i)*p.hashLen:names+(lo+i+1)*p.hashLen], name) >= 0
+	})
+	if i < hi && bytes.Equal(p.idx[names+i*p.hashLen:names+(i+1)*p.hashLen], name) {
+		offsets := names + p.count*(p.hashLen+4)
+		offset = int64(binary.BigEndian.Uint32(p.idx[offsets+i*4:]))
+		if offset&0x80000000 != 0 {
+			large := offsets + p.count*4 + int(offset&0x7fffffff)*8
+			if large+8 > len(p.idx) {
+				return 0, false
+			}
+			offset = int64(binary.BigEndian.Uint64(p.idx[large:])) // #nosec G115
+		}
+		ok = true
+	}
+	return
+}
+
+// readAt returns the object at offset, resolving deltas.
+func (p *gitPack) readAt(r *gitRepo, offset int64) (kind int, data []byte, err error) {
+	if obj, ok := p.cache[offset]; ok {
+		return obj.kind, obj.data, nil
+	}
+	var header [64]byte
+	var n int
+	if n, err = p.f.ReadAt(header[:], offset); n > 0 {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	b := header[0]
+	kind = int(b>>4) & 7
+	size := int64(b & 0x0f)
+	i, shift := 1, 4
+	for b&0x80 != 0 && i < n {
+		b = header[i]
+		size |= int64(b&0x7f) << shift
+		shift += 7
+		i++
+	}
+	var baseKind int
+	var base []byte
+	switch kind {
+	case objOfsDelta:
+		b = 0x80
+		var rel int64
+		for j := 0; b&0x80 != 0 && i < n; j++ {
+			b = header[i]
+			if j > 0 {
+				rel++
+			}
+			rel = rel<<7 | int64(b&0x7f)
+			i++
+		}
+		if rel <= 0 || rel > offset {
+			return 0, nil, fmt.Errorf("%s: bad delta offset", p.f.Name())
+		}
+		baseKind, base, err = p.readAt(r, offset-rel)
+	case objRefDelta:
+		if i+p.hashLen > n {
+			return 0, nil, fmt.Errorf("%s: truncated object", p.f.Name())
+		}
+		baseKind, base, err = r.readObject(hex.EncodeToString(header[i : i+p.hashLen]))
+		i += p.hashLen
+	case objCommit, objTree, objBlob, objTag:
+	default:
+		err = fmt.Errorf("%s: unknown object type %d", p.f.Name(), kind)
+	}
+	if err == nil {
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bufio.NewReader(io.NewSectionReader(p.f, offset+int64(i), 1<<62))); err == nil {
+			data = make([]byte, size)
+			_, err = io.ReadFull(zr, data)
+			_ = zr.Close()
+			if err == nil && base != nil {
+				kind = baseKind
+				data, err = applyDelta(base, data)
+			}
+		}
+	}
+	if err == nil && len(data) < 1<<20 {
+		if len(p.cache) >= packCacheSize {
+			clear(p.cache)
+		}
+		p.cache[offset] = packObject{kind: kind, data: data}
+	}
+	return
+}
+
+var errBadDelta = errors.New("bad delta")
+
+// deltaSize reads a little-endian base 128 number from the start of delta.
+func deltaSize(delta []byte) (size int, rest []byte, err error) {
+	shift := 0
+	for i, b := range delta {
+		size |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+		if shift += 7; shift > 56 {
+			break
+		}
+	}
+	return 0, nil, errBadDelta
+}
+
+func applyDelta(base, delta []byte) (out []byte, err error) {
+	var srcSize, dstSize int
+	if srcSize, delta, err = deltaSize(delta); err == nil {
+		if dstSize, delta, err = deltaSize(delta); err == nil {
+			if srcSize != len(base) {
+				return nil, errBadDelta
+			}
+			out = make([]byte, 0, dstSize)
+			for len(delta) > 0 && err == nil {
+				op := delta[0]
+				delta = delta[1:]
+				switch {
+				case op&0x80 != 0:
+					var vals [7]int
+					for bit := range vals {
+						if op&(1<<bit) != 0 {
+							if len(delta) == 0 {
+								return nil, errBadDelta
+							}
+							vals[bit] = int(delta[0])
+							delta = delta[1:]
+						}
+					}
+					offset := vals[0] | vals[1]<<8 | vals[2]<<16 | vals[3]<<24
+					size := vals[4] | vals[5]<<8 | vals[6]<<16
+					if size == 0 {
+						size = 0x10000
+					}
+					if offset+size > len(base) {
+						return nil, errBadDelta
+					}
+					out = append(out, base[offset:offset+size]...)
+				case op != 0:
+					if int(op) > len(delta) {
+						return nil, errBadDelta
+					}
+					out = append(out, delta[:op]...)
+					delta = delta[op:]
+				default:
+					err = errBadDelta
+				}
+			}
+			if err == nil && len(out) != dstSize {
+				err = errBadDelta
+			}
+		}
+	}
+	return
+}
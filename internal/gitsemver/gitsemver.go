@@ -4,7 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // GitSemVer holds git metadata used while computing a version.
@@ -15,18 +20,35 @@ import (
 // Reusing a single instance across repeated GetVersion calls after repository
 // changes is also unsupported for the same reason.
 type GitSemVer struct {
-	Git         Gitter      // Git
-	Env         Environment // environment
-	DebugOut    io.Writer   // if nit nil, write debug output here
-	cleanstatus bool        // true if there are no uncommitted changes in current tree
-	tags        []GitTag    // cached tags for one repo during one version computation
+	Git             Gitter         // Git
+	Env             Environment    // environment
+	DebugOut        io.Writer      // if nit nil, write debug output here
+	Style           VersionStyle   // version style set in VersionInfo by GetVersion
+	TagPrefix       string         // only use tags starting with this, e.g. "tools/cli/" for a module in that directory
+	TreePath        string         // if not empty, compare the hashes of this slash-separated path instead of the root trees
+	BuildMode       BuildMode      // how GetVersion computes the build number
+	ReleaseBranches []string       // if not empty, patterns for release branches instead of the default branch names, see MatchBranch
+	BumpRules       BumpRules      // Conventional Commits types and their bumps for GetBump, nil for DefaultBumpRules
+	NoCommitTime    bool           // if true, VersionInfo.CommitTime is only set when known without asking Git
+	Prereleases     bool           // if true, prerelease tags can be the current tag, as needed to continue or promote them
+	cleanstatus     bool           // true if there are no uncommitted changes in current tree
+	cleanknown      bool           // true if cleanstatus has been determined
+	tags            []GitTag       // cached tags for one repo during one version computation
+	tagIndex        map[string]int // index in tags by tag name
+	scanned         []string       // names of the scanned tags, sorted by version descending
+	tagsScanned     bool           // true if scanned is set
 }
 
-// New returns a GitSemVer ready to examine
-// the git repositories using the given Git binary.
+// New returns a GitSemVer ready to examine the git repositories using the
+// given Git binary, or the InternalGitter if gitBin is GitInternal.
 func New(gitBin string, debugOut io.Writer) (vs *GitSemVer, err error) {
 	var git Gitter
-	if git, err = NewDefaultGitter(gitBin, debugOut); err == nil {
+	if gitBin == GitInternal {
+		git, err = NewInternalGitter("git", debugOut)
+	} else {
+		git, err = NewDefaultGitter(gitBin, debugOut)
+	}
+	if err == nil {
 		vs = &GitSemVer{
 			Git:      git,
 			Env:      OsEnvironment{},
@@ -55,6 +77,11 @@ func (vs *GitSemVer) IsReleaseBranch(branchName string) bool {
 	// If the branch isn't protected, we only allow release
 	// mode for the 'default' branch.
 
+	// Explicitly configured release branches replace the defaults.
+	if len(vs.ReleaseBranches) > 0 {
+		return branchName == "" || MatchBranch(vs.ReleaseBranches, branchName)
+	}
+
 	// GitLab gives us the default branch name directly.
 	if defBranch, ok := vs.Env.LookupEnv("CI_DEFAULT_BRANCH"); ok {
 		return branchName == defBranch
@@ -75,6 +102,62 @@ func (vs *GitSemVer) IsReleaseBranch(branchName string) bool {
 	return false
 }
 
+// MatchBranch returns true if branchName matches any of the patterns.
+// Patterns starting with '^' are regular expressions, others are
+// glob patterns as used by path.Match, where '*' doesn't match '/'.
+func MatchBranch(patterns []string, branchName string) (ok bool) {
+	_, ok = matchBranch(patterns, branchName)
+	return
+}
+
+// CheckBranchPattern returns an error if pattern is not a valid MatchBranch pattern.
+func CheckBranchPattern(pattern string) (err error) {
+	if strings.HasPrefix(pattern, "^") {
+		_, err = regexp.Compile(pattern)
+	} else {
+		_, err = path.Match(pattern, "")
+	}
+	return
+}
+
+// matchBranch returns true and the version line of branchName if it matches any of
+// the patterns. A regular expression may give the line with the named groups "major"
+// and optionally "minor", otherwise it's taken from the end of the branch name.
+func matchBranch(patterns []string, branchName string) (line *VersionLine, ok bool) {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "^") {
+			if re, err := regexp.Compile(pattern); err == nil {
+				if m := re.FindStringSubmatch(branchName); m != nil {
+					if i := re.SubexpIndex("major"); i > 0 {
+						minor := ""
+						if j := re.SubexpIndex("minor"); j > 0 {
+							minor = m[j]
+						}
+						return newVersionLine(m[i], minor), true
+					}
+					return ParseVersionLine(branchName), true
+				}
+			}
+		} else if ok, _ = path.Match(pattern, branchName); ok {
+			return ParseVersionLine(branchName), true
+		}
+	}
+	return
+}
+
+// ReleaseLine returns the version line of a release branch, taken from the
+// ReleaseBranches pattern it matches or from the end of the branch name, like
+// 1.2 for "release/1.2". Returns nil if it's not a release branch or has no line.
+func (vs *GitSemVer) ReleaseLine(branchName string) (line *VersionLine) {
+	if branchName != "" && vs.IsReleaseBranch(branchName) {
+		var ok bool
+		if line, ok = matchBranch(vs.ReleaseBranches, branchName); !ok {
+			line = ParseVersionLine(branchName)
+		}
+	}
+	return
+}
+
 // Debug writes debugging output to DebugOut if it's not nil.
 func (vs *GitSemVer) Debug(f string, args ...any) {
 	if vs.DebugOut != nil {
@@ -82,24 +165,61 @@ func (vs *GitSemVer) Debug(f string, args ...any) {
 	}
 }
 
-func (vs *GitSemVer) cacheTag(gt GitTag) {
-	for i := range vs.tags {
-		if vs.tags[i].Tag == gt.Tag {
-			vs.tags[i] = gt
-			return
+// Refresh forgets the cached tags and working tree status, and closes the Git
+// if it is an io.Closer so that it reopens the repository. Call it after the
+// repository changed, as after fetching new tags.
+func (vs *GitSemVer) Refresh() {
+	vs.cleanknown = false
+	vs.tags, vs.tagIndex = nil, nil
+	vs.scanned, vs.tagsScanned = nil, false
+	if closer, ok := vs.Git.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// cachedTag returns the index of tag in the cached tags, or -1.
+func (vs *GitSemVer) cachedTag(tag string) int {
+	if vs.tagIndex == nil || len(vs.tagIndex) != len(vs.tags) {
+		vs.tagIndex = make(map[string]int, len(vs.tags))
+		for i := range vs.tags {
+			vs.tagIndex[vs.tags[i].Tag] = i
 		}
 	}
+	if i, ok := vs.tagIndex[tag]; ok {
+		return i
+	}
+	return -1
+}
+
+func (vs *GitSemVer) cacheTag(gt GitTag) {
+	if i := vs.cachedTag(gt.Tag); i >= 0 {
+		vs.tags[i] = gt
+		return
+	}
+	vs.tagIndex[gt.Tag] = len(vs.tags)
 	vs.tags = append(vs.tags, gt)
 }
 
+func (vs *GitSemVer) getHashes(repo, tag string) (commit, tree string, err error) {
+	if vs.TreePath != "" {
+		return vs.Git.GetPathHashes(repo, tag, vs.TreePath)
+	}
+	return vs.Git.GetHashes(repo, tag)
+}
+
+func (vs *GitSemVer) getHashesBatch(repo string, tags []string) (hashes []GitTag, err error) {
+	if vs.TreePath != "" {
+		return vs.Git.GetPathHashesBatch(repo, tags, vs.TreePath)
+	}
+	return vs.Git.GetHashesBatch(repo, tags)
+}
+
 func (vs *GitSemVer) getTreeHash(repo, tag string) (gt GitTag, err error) {
-	for i := range vs.tags {
-		if vs.tags[i].Tag == tag {
-			return vs.tags[i], nil
-		}
+	if i := vs.cachedTag(tag); i >= 0 {
+		return vs.tags[i], nil
 	}
 	var commit, tree string
-	if commit, tree, err = vs.Git.GetHashes(repo, tag); commit != "" && tree != "" && err == nil {
+	if commit, tree, err = vs.getHashes(repo, tag); commit != "" && tree != "" && err == nil {
 		gt.Tag = tag
 		gt.Commit = commit
 		gt.Tree = tree
@@ -108,29 +228,104 @@ func (vs *GitSemVer) getTreeHash(repo, tag string) (gt GitTag, err error) {
 	return
 }
 
-func (vs *GitSemVer) examineTags(repo string) (err error) {
-	// Version detection should ignore CI-generated untracked files.
-	if vs.cleanstatus, err = vs.Git.CleanStatus(repo, false); err == nil {
-		var headHashes GitTag
-		if headHashes, err = vs.getTreeHash(repo, "HEAD"); err == nil {
-			vs.Debug("treehash %s: HEAD (clean: %v)\n", headHashes.Tree, vs.cleanstatus)
-			var tags []string
-			if tags, err = vs.Git.GetTags(repo); err == nil {
-				if batched, batchErr := vs.Git.GetHashesBatch(repo, tags); batchErr == nil {
-					for _, gt := range batched {
-						vs.cacheTag(gt)
-					}
-				} else {
-					vs.Debug("treehash batch lookup failed, falling back to per-tag: %v\n", batchErr)
-				}
-				for _, testtag := range tags {
-					var tagtreehashes GitTag
-					if tagtreehashes, err = vs.getTreeHash(repo, testtag); err == nil {
-						if tagtreehashes.Tree != "" {
-							vs.Debug("treehash %s: %q\n", tagtreehashes.Tree, testtag)
-							if vs.cleanstatus && tagtreehashes.Tree == headHashes.Tree {
-								return
-							}
+// getCleanStatus returns the cached result of CleanStatus, ignoring untracked files.
+func (vs *GitSemVer) getCleanStatus(repo string) (clean bool, err error) {
+	if !vs.cleanknown {
+		// Version detection should ignore CI-generated untracked files.
+		vs.cleanstatus, err = vs.Git.CleanStatus(repo, false)
+		vs.cleanknown = err == nil
+	}
+	return vs.cleanstatus, err
+}
+
+// headRev returns rev, or "HEAD" if rev is empty.
+func headRev(rev string) string {
+	if rev == "" {
+		return "HEAD"
+	}
+	return rev
+}
+
+// isClean returns the clean status of the working tree if rev is empty,
+// otherwise true, as a revision is treated as a clean checkout of it.
+func (vs *GitSemVer) isClean(repo, rev string) (clean bool, err error) {
+	if rev == "" {
+		return vs.getCleanStatus(repo)
+	}
+	return true, nil
+}
+
+// usableTag returns true if the tag is not above the version line, if any,
+// and not a prerelease unless Prereleases is set.
+func (vs *GitSemVer) usableTag(line *VersionLine, tag string) bool {
+	tag = strings.TrimPrefix(tag, vs.TagPrefix)
+	return (line == nil || line.Compare(tag) <= 0) && (vs.Prereleases || !isPrereleaseTag(tag))
+}
+
+// allTags returns the names of all tags, sorted by version descending.
+// The tags and their hashes are only scanned and cached by the first call.
+func (vs *GitSemVer) allTags(repo string) (tags []string, err error) {
+	if !vs.tagsScanned {
+		if vs.scanned, err = vs.scanAllTags(repo); err != nil {
+			return
+		}
+		vs.tagsScanned = true
+	}
+	return vs.scanned, nil
+}
+
+// scanTags returns the names of the usable tags, sorted by version descending.
+func (vs *GitSemVer) scanTags(repo string, line *VersionLine) (tags []string, err error) {
+	var all []string
+	if all, err = vs.allTags(repo); err == nil {
+		for _, tag := range all {
+			if vs.usableTag(line, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return
+}
+
+// scanAllTags caches the hashes of the tags and returns their names, sorted by
+// version descending. Without a TreePath, a single GetTagHashes call is enough.
+func (vs *GitSemVer) scanAllTags(repo string) (tags []string, err error) {
+	if vs.TreePath == "" {
+		var hashes []GitTag
+		if hashes, err = vs.Git.GetTagHashes(repo, vs.TagPrefix); err == nil {
+			for _, gt := range hashes {
+				vs.cacheTag(gt)
+				tags = append(tags, gt.Tag)
+			}
+			return
+		}
+		vs.Debug("tag scan failed, falling back to batch lookup: %v\n", err)
+	}
+	if tags, err = vs.Git.GetTags(repo, vs.TagPrefix); err == nil {
+		if batched, batchErr := vs.getHashesBatch(repo, tags); batchErr == nil {
+			for _, gt := range batched {
+				vs.cacheTag(gt)
+			}
+		} else {
+			vs.Debug("treehash batch lookup failed, falling back to per-tag: %v\n", batchErr)
+		}
+	}
+	return
+}
+
+func (vs *GitSemVer) examineTags(repo, head string, clean bool, line *VersionLine) (err error) {
+	var headHashes GitTag
+	if headHashes, err = vs.getTreeHash(repo, head); err == nil {
+		vs.Debug("treehash %s: %s (clean: %v)\n", headHashes.Tree, head, clean)
+		var tags []string
+		if tags, err = vs.scanTags(repo, line); err == nil {
+			for _, testtag := range tags {
+				var tagtreehashes GitTag
+				if tagtreehashes, err = vs.getTreeHash(repo, testtag); err == nil {
+					if tagtreehashes.Tree != "" {
+						vs.Debug("treehash %s: %q\n", tagtreehashes.Tree, testtag)
+						if clean && tagtreehashes.Tree == headHashes.Tree {
+							return
 						}
 					}
 				}
@@ -143,35 +338,73 @@ func (vs *GitSemVer) examineTags(repo string) (err error) {
 // GetTag returns the semver git version tag matching the current tree, or
 // the closest semver tag if none match exactly. It also returns a bool
 // that is true if the tree hashes match and there are no uncommitted changes.
+// Only tags starting with TagPrefix are considered, and the returned tag
+// includes the prefix.
 func (vs *GitSemVer) GetTag(repo string) (tag string, match bool, err error) {
-	if ciTag := vs.Env.Getenv("CI_COMMIT_TAG"); ciTag != "" {
-		if isSemverTag(ciTag) {
+	return vs.getTag(repo, "", nil, nil)
+}
+
+// getTag is GetTag for rev, or HEAD and the working tree if rev is empty,
+// ignoring tags above the version line, if any. The nearest tag is found
+// in g if it has rev, otherwise by the Gitter.
+func (vs *GitSemVer) getTag(repo, rev string, line *VersionLine, g *commitGraph) (tag string, match bool, err error) {
+	if ciTag := vs.Env.Getenv("CI_COMMIT_TAG"); ciTag != "" && rev == "" {
+		if isPrefixedSemverTag(vs.TagPrefix, ciTag) {
 			return ciTag, true, nil
 		}
 	}
-	tag = "v0.0.0"
-	if err = vs.examineTags(repo); err == nil {
-		var head GitTag
-		if head, err = vs.getTreeHash(repo, "HEAD"); err == nil {
-			for _, gt := range vs.tags {
-				if gt.Tag != "HEAD" && gt.Tree == head.Tree {
-					return gt.Tag, vs.cleanstatus, nil
+	tag = vs.TagPrefix + "v0.0.0"
+	var clean bool
+	if clean, err = vs.isClean(repo, rev); err == nil {
+		if err = vs.examineTags(repo, headRev(rev), clean, line); err == nil {
+			var head GitTag
+			var candidates []GitTag
+			if head, err = vs.getTreeHash(repo, headRev(rev)); err == nil {
+				for _, name := range vs.scanned {
+					if i := vs.cachedTag(name); i >= 0 && vs.usableTag(line, name) {
+						gt := vs.tags[i]
+						if gt.Tree == head.Tree {
+							return gt.Tag, clean, nil
+						}
+						candidates = append(candidates, gt)
+					}
 				}
 			}
-		}
-		var closeToHEAD string
-		if closeToHEAD, err = vs.Git.GetClosestTag(repo, "HEAD"); err == nil && closeToHEAD != "" {
-			var found GitTag
-			if found, err = vs.getTreeHash(repo, closeToHEAD); err == nil {
-				vs.Debug("treehash %s: %q is closest to HEAD\n", found.Tree, found.Tag)
-				return found.Tag, vs.cleanstatus && (found.Tree == head.Tree), nil
+			// tags on the same commit are chosen like "git describe" does
+			sortDescribe(candidates)
+			var closest string
+			var distance int
+			if g != nil && g.has(rev) {
+				closest, distance = g.nearestTag(rev, candidates)
+			} else {
+				closest, distance, err = vs.Git.GetNearestTag(repo, candidates, headRev(rev))
+			}
+			if err == nil && closest != "" {
+				var found GitTag
+				if found, err = vs.getTreeHash(repo, closest); err == nil {
+					vs.Debug("treehash %s: %q is closest to %s, %d commits behind\n", found.Tree, found.Tag, headRev(rev), distance)
+					return found.Tag, clean && (found.Tree == head.Tree), nil
+				}
 			}
 		}
 	}
 	return
 }
 
+// isGitHub returns true if running in GitHub Actions.
+func (vs *GitSemVer) isGitHub() bool {
+	return vs.IsEnvTrue("GITHUB_ACTIONS")
+}
+
+// isGitLab returns true if running in GitLab CI.
+func (vs *GitSemVer) isGitLab() bool {
+	return vs.IsEnvTrue("GITLAB_CI")
+}
+
 func (vs *GitSemVer) getBranchGitHub(repo string) (branchName string, err error) {
+	if !vs.isGitHub() {
+		return
+	}
 	if branchName = vs.Env.Getenv("GITHUB_BASE_REF"); branchName == "" {
 		if refName := vs.Env.Getenv("GITHUB_REF_NAME"); refName != "" {
 			switch vs.Env.Getenv("GITHUB_REF_TYPE") {
@@ -194,6 +427,9 @@ func (vs *GitSemVer) getBranchGitHub(repo string) (branchName string, err error)
 }
 
 func (vs *GitSemVer) getBranchGitLab(repo string) (branchName string, err error) {
+	if !vs.isGitLab() {
+		return
+	}
 	if branchName = vs.Env.Getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"); branchName == "" {
 		if branchName = vs.Env.Getenv("CI_EXTERNAL_PULL_REQUEST_TARGET_BRANCH_NAME"); branchName == "" {
 			if branchName = vs.Env.Getenv("CI_COMMIT_REF_NAME"); branchName != "" {
@@ -228,6 +464,41 @@ func (vs *GitSemVer) GetBranch(repo string) (branchName string, err error) {
 	return
 }
 
+// GetPushBranch returns the branch a new commit on HEAD should be pushed to.
+// This is the current branch in Git, or the branch the CI system checked out.
+// Unlike GetBranch, the target branch of a merge or pull request is never
+// returned. If no branch can be found an empty string is returned.
+func (vs *GitSemVer) GetPushBranch(repo string) (branchName string, err error) {
+	if branchName, err = vs.Git.GetBranch(repo); branchName == "" {
+		if vs.isGitLab() {
+			branchName = vs.Env.Getenv("CI_COMMIT_BRANCH")
+		}
+		if branchName == "" && vs.isGitHub() && vs.Env.Getenv("GITHUB_REF_TYPE") == "branch" {
+			branchName = vs.Env.Getenv("GITHUB_REF_NAME")
+		}
+	}
+	return
+}
+
+// getBranchAt returns the branch to use for the version of rev: name if it
+// names a branch that has rev, otherwise the first release branch that has it.
+// Returns an empty string if no branch qualifies, as for a detached HEAD.
+func (vs *GitSemVer) getBranchAt(repo, rev, name string) (branchName string, err error) {
+	var branches []string
+	if branches, err = vs.Git.GetBranchesContaining(repo, rev); err == nil {
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "refs/"), "heads/")
+		if slices.Contains(branches, name) {
+			return name, nil
+		}
+		for _, branchName = range branches {
+			if vs.IsReleaseBranch(branchName) {
+				return
+			}
+		}
+	}
+	return "", err
+}
+
 // GetBuild returns the build counter. This is taken from the CI system if available,
 // otherwise the Git commit count is used. Returns an empty string if no reasonable build
 // counter can be found.
@@ -240,20 +511,177 @@ func (vs *GitSemVer) GetBuild(repo string) (build string, err error) {
 	return
 }
 
+// getBuild returns the build number of rev, or HEAD if rev is empty, according
+// to BuildMode. The tagCommit is the commit of the matched tag, or empty if the
+// tag doesn't exist. The CI build counters are only used for HEAD. Commits are
+// counted in g if it has them and no path is involved, otherwise by the Gitter.
+func (vs *GitSemVer) getBuild(repo, rev, tagCommit string, g *commitGraph) (build string, err error) {
+	since, path := "", vs.TreePath
+	switch vs.BuildMode {
+	case BuildAll:
+		if rev == "" {
+			return vs.GetBuild(repo)
+		}
+		path = ""
+	case BuildHeight:
+		since = tagCommit
+	}
+	var count int
+	if g != nil && path == "" && g.has(rev) && (since == "" || g.has(since)) {
+		count = g.countCommits(rev, since)
+	} else if count, err = vs.Git.CountCommits(repo, headRev(rev), since, path); err != nil {
+		return
+	}
+	build = strconv.Itoa(count)
+	return
+}
+
+// GetBump returns the version increment implied by the Conventional Commits
+// messages of the commits between the given tag and HEAD. If the tag is not
+// known, all commits reachable from HEAD are considered.
+func (vs *GitSemVer) GetBump(repo, tag string) (bump Bump, err error) {
+	from := ""
+	if tag != "HEAD" {
+		// An unknown tag (like the "v0.0.0" default) means there is no lower bound.
+		if gt, e := vs.getTreeHash(repo, tag); e == nil {
+			from = gt.Commit
+		}
+	}
+	var messages []string
+	if messages, err = vs.Git.GetCommitMessages(repo, from, "HEAD"); err == nil {
+		rules := vs.BumpRules
+		if rules == nil {
+			rules = DefaultBumpRules
+		}
+		bump = rules.Bumps(messages)
+		vs.Debug("bump %s: %d commits since %q\n", bump, len(messages), tag)
+	}
+	return
+}
+
 // GetVersion returns a VersionInfo for the source code in the Git repository.
 // A GitSemVer instance should be treated as single-snapshot state: if the repo
 // changes, create a new GitSemVer before calling GetVersion again.
 func (vs *GitSemVer) GetVersion(repo string) (vi VersionInfo, err error) {
 	if repo, err = vs.Git.CheckGitRepo(repo); err == nil {
-		if vi.Tag, vi.SameTree, err = vs.GetTag(repo); vi.Tag != "" && err == nil {
-			var e error
-			vi.Build, e = vs.GetBuild(repo)
-			err = errors.Join(err, e)
-			vi.Branch, e = vs.GetBranch(repo)
+		vi, err = vs.getVersion(repo, "", "")
+	}
+	return
+}
+
+// GetVersionAt returns a VersionInfo for the given revision as if it was
+// checked out without uncommitted changes, without checking it out.
+// The branch is rev itself if it names a branch, otherwise the first
+// release branch that has the commit, if any. The CI build counters,
+// branch and tag variables aren't used, since they describe HEAD.
+func (vs *GitSemVer) GetVersionAt(repo, rev string) (vi VersionInfo, err error) {
+	if repo, err = vs.Git.CheckGitRepo(repo); err == nil {
+		vi, err = vs.getVersion(repo, headRev(rev), headRev(rev))
+	}
+	return
+}
+
+// GetVersions returns a VersionInfo for each commit in the revision range, like
+// "A..B", children before their parents, as GetVersionAt would for the commit.
+// The branch is the one GetVersionAt gives the end of the range, and is used for
+// all the commits. The tags are only scanned once, the commit hashes looked up
+// together, and the nearest tags and build numbers found in the history of the
+// end of the range, which is only read once. Only build numbers counting the
+// commits that touch TreePath are asked of Git for each commit.
+func (vs *GitSemVer) GetVersions(repo, revRange string) (versions []VersionInfo, err error) {
+	if repo, err = vs.Git.CheckGitRepo(repo); err == nil {
+		var commits []string
+		if commits, err = vs.Git.GetCommits(repo, revRange); err == nil && len(commits) > 0 {
+			if batched, batchErr := vs.getHashesBatch(repo, commits); batchErr == nil {
+				for _, gt := range batched {
+					vs.cacheTag(gt)
+				}
+			} else {
+				vs.Debug("treehash batch lookup failed, falling back to per-commit: %v\n", batchErr)
+			}
+			end := rangeEnd(revRange)
+			var g *commitGraph
+			if grapher, ok := vs.Git.(commitGrapher); ok {
+				var graphErr error
+				if g, graphErr = grapher.commitGraph(repo, end); graphErr != nil {
+					vs.Debug("commit graph of %q failed, falling back to per-commit: %v\n", end, graphErr)
+					g = nil
+				}
+			}
+			branch, branchErr := vs.getBranchAt(repo, end, end)
+			for _, commit := range commits {
+				var vi VersionInfo
+				if vi, err = vs.getVersionOn(repo, commit, branch, branchErr, g); err != nil {
+					return nil, err
+				}
+				versions = append(versions, vi)
+			}
+		}
+	}
+	return
+}
+
+// rangeEnd returns the revision a revision range like "A..B" ends at.
+func rangeEnd(revRange string) (end string) {
+	for _, field := range strings.Fields(revRange) {
+		if !strings.HasPrefix(field, "^") {
+			end = field
+		}
+	}
+	if i := strings.LastIndex(end, ".."); i >= 0 {
+		end = end[i+2:]
+	}
+	return headRev(end)
+}
+
+// getVersion returns the VersionInfo for rev, or for HEAD and the working tree if rev
+// is empty. The branch for rev is name if it names a branch that has rev.
+func (vs *GitSemVer) getVersion(repo, rev, name string) (vi VersionInfo, err error) {
+	// The branch is needed first, since a release branch may limit the tags.
+	var branch string
+	var branchErr error
+	if rev == "" {
+		branch, branchErr = vs.GetBranch(repo)
+	} else {
+		branch, branchErr = vs.getBranchAt(repo, rev, name)
+	}
+	return vs.getVersionOn(repo, rev, branch, branchErr, nil)
+}
+
+// getVersionOn is getVersion with the branch already found, and branchErr
+// the error finding it, if any. If g is not nil, the nearest tag, the build
+// number and the commit time are taken from it where it has the commits.
+func (vs *GitSemVer) getVersionOn(repo, rev, branch string, branchErr error, g *commitGraph) (vi VersionInfo, err error) {
+	line := vs.ReleaseLine(branch)
+	var tag string
+	tag, vi.SameTree, err = vs.getTag(repo, rev, line, g)
+	vi.Tag, vi.TagPrefix = strings.TrimPrefix(tag, vs.TagPrefix), vs.TagPrefix
+	if vi.Tag != "" && err == nil {
+		// The tag may not exist, e.g. the "v0.0.0" default.
+		if gt, e := vs.getTreeHash(repo, tag); e == nil {
+			vi.TagCommit = gt.Commit
+		}
+		var e error
+		vi.Build, e = vs.getBuild(repo, rev, vi.TagCommit, g)
+		err = errors.Join(err, e, branchErr)
+		vi.Branch, vi.Line = branch, line
+		vi.IsRelease = vs.IsReleaseBranch(vi.Branch)
+		vi.Style = vs.Style
+		var head GitTag
+		head, e = vs.getTreeHash(repo, headRev(rev))
+		err = errors.Join(err, e)
+		vi.Commit, vi.Tree = head.Commit, head.Tree
+		if g != nil && g.has(vi.Commit) {
+			vi.CommitTime = time.Unix(g.when[vi.Commit], 0)
+		} else if vi.Commit != "" && !vs.NoCommitTime {
+			vi.CommitTime, e = vs.Git.GetCommitTime(repo, vi.Commit)
 			err = errors.Join(err, e)
-			vi.IsRelease = vs.IsReleaseBranch(vi.Branch)
-			vi.Tags = vs.tags
 		}
+		var clean bool
+		clean, e = vs.isClean(repo, rev)
+		err = errors.Join(err, e)
+		vi.Dirty = !clean
+		vi.Tags = vs.tags
 	}
 	return
 }
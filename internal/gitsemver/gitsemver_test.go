@@ -5,9 +5,11 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
 )
@@ -48,9 +50,15 @@ func isTrue(t *testing.T, v bool) {
 
 type MockBatchErrorGitter struct {
 	*MockGitter
+	scanCalls  int
 	batchCalls int
 }
 
+func (mg *MockBatchErrorGitter) GetTagHashes(repo, prefix string) (tags []gitsemver.GitTag, err error) {
+	mg.scanCalls++
+	return nil, errors.New("scan failed")
+}
+
 func (mg *MockBatchErrorGitter) GetHashesBatch(repo string, tags []string) (hashes []gitsemver.GitTag, err error) {
 	mg.batchCalls++
 	return nil, errors.New("batch failed")
@@ -100,6 +108,79 @@ func Test_VersionStringer_IsReleaseBranch(t *testing.T) {
 	isTrue(t, !vs.IsReleaseBranch(branchName))
 }
 
+func Test_VersionStringer_IsReleaseBranch_ReleaseBranches(t *testing.T) {
+	env := MockEnvironment{"CI_DEFAULT_BRANCH": "main"}
+	vs := gitsemver.GitSemVer{Env: env, ReleaseBranches: []string{"trunk", "release/*"}}
+
+	isTrue(t, vs.IsReleaseBranch("trunk"))
+	isTrue(t, vs.IsReleaseBranch("release/1.2"))
+	isTrue(t, vs.IsReleaseBranch(""))
+	isTrue(t, !vs.IsReleaseBranch("main"))
+	isTrue(t, !vs.IsReleaseBranch("release/1.2/fix"))
+
+	env["GITHUB_REF_PROTECTED"] = "true"
+	isTrue(t, vs.IsReleaseBranch("main"))
+}
+
+func Test_MatchBranch(t *testing.T) {
+	patterns := []string{"main", "hotfix/*", `^maint-(?P<major>\d+)$`}
+	isTrue(t, gitsemver.MatchBranch(patterns, "main"))
+	isTrue(t, gitsemver.MatchBranch(patterns, "hotfix/x"))
+	isTrue(t, gitsemver.MatchBranch(patterns, "maint-3"))
+	isTrue(t, !gitsemver.MatchBranch(patterns, "maint-3x"))
+	isTrue(t, !gitsemver.MatchBranch(patterns, "hotfix/x/y"))
+	isTrue(t, !gitsemver.MatchBranch([]string{"^("}, "("))
+
+	isEqual(t, nil, gitsemver.CheckBranchPattern("release/*"))
+	isEqual(t, nil, gitsemver.CheckBranchPattern(`^release/\d+$`))
+	isTrue(t, gitsemver.CheckBranchPattern("[") != nil)
+	isTrue(t, gitsemver.CheckBranchPattern("^(") != nil)
+}
+
+func Test_VersionStringer_ReleaseLine(t *testing.T) {
+	vs := gitsemver.GitSemVer{Env: MockEnvironment{}}
+	isTrue(t, vs.ReleaseLine("release/1.2") == nil)
+	isTrue(t, vs.ReleaseLine("main") == nil)
+	isTrue(t, vs.ReleaseLine("") == nil)
+
+	vs.ReleaseBranches = []string{"main", "release/*", `^maint-(?P<major>\d+)-(?P<minor>\d+)-lts$`}
+	isEqual(t, "1.2", vs.ReleaseLine("release/1.2").String())
+	isEqual(t, "3.4", vs.ReleaseLine("maint-3-4-lts").String())
+	isTrue(t, vs.ReleaseLine("release/next") == nil)
+	isTrue(t, vs.ReleaseLine("feature/1.2") == nil)
+
+	vs = gitsemver.GitSemVer{Env: MockEnvironment{"CI_COMMIT_REF_PROTECTED": "true"}}
+	isEqual(t, "2", vs.ReleaseLine("v2").String())
+}
+
+func Test_VersionStringer_GetVersion_ReleaseLine(t *testing.T) {
+	git := &MockGitter{branch: "release/4.x"}
+	vs := gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}}
+	vi, err := vs.GetVersion(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "v6.0.0", vi.Tag)
+	isTrue(t, vi.Line == nil)
+
+	vs = gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}, ReleaseBranches: []string{"release/*"}}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "v4.0.0", vi.Tag)
+	isEqual(t, "4", vi.Line.String())
+	isEqual(t, "commit-4", vi.TagCommit)
+
+	// the tree of HEAD matches a tag above the line
+	git.treehash = "tree-6"
+	vs = gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}, ReleaseBranches: []string{"release/*"}}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "v4.0.0", vi.Tag)
+	isEqual(t, false, vi.SameTree)
+}
+
 func Test_VersionStringer_GetTag(t *testing.T) {
 	env := MockEnvironment{}
 	git := &MockGitter{}
@@ -167,11 +248,12 @@ func Test_VersionStringer_GetTag_BatchFailureFallsBackToPerTagLookup(t *testing.
 	}
 	isEqual(t, "v6.0.0", tag)
 	isEqual(t, false, sametree)
+	isEqual(t, 1, git.scanCalls)
 	isEqual(t, 1, git.batchCalls)
 }
 
 func Test_VersionStringer_GetBranch(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true", "GITLAB_CI": "true"}
 	git := &MockGitter{}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -260,7 +342,7 @@ func Test_VersionStringer_GetBranch(t *testing.T) {
 }
 
 func Test_VersionStringer_GetBranchFromTag_GitLab(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITLAB_CI": "true"}
 	git := &MockGitter{}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -272,7 +354,7 @@ func Test_VersionStringer_GetBranchFromTag_GitLab(t *testing.T) {
 }
 
 func Test_VersionStringer_GetBranchFromTag_GitHub(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true"}
 	git := &MockGitter{}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -290,7 +372,7 @@ func Test_VersionStringer_GetBranchFromTag_GitHub(t *testing.T) {
 }
 
 func Test_VersionStringer_GetBranchFromBranchRef_GitHub(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true"}
 	git := &MockGitter{branch: "detached"}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -301,8 +383,55 @@ func Test_VersionStringer_GetBranchFromBranchRef_GitHub(t *testing.T) {
 	isEqual(t, "feature/foo", name)
 }
 
+func Test_VersionStringer_GetBranch_NoCI(t *testing.T) {
+	env := MockEnvironment{
+		"GITHUB_REF_TYPE":    "branch",
+		"GITHUB_REF_NAME":    "feature/foo",
+		"CI_COMMIT_REF_NAME": "feature/bar",
+		"CI_COMMIT_BRANCH":   "feature/bar",
+	}
+	git := &MockGitter{branch: "detached"}
+	vs := gitsemver.GitSemVer{Git: git, Env: env}
+
+	name, err := vs.GetBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "", name)
+	name, err = vs.GetPushBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "", name)
+}
+
+func Test_VersionStringer_GetPushBranch(t *testing.T) {
+	env := MockEnvironment{"GITHUB_ACTIONS": "true", "GITLAB_CI": "true"}
+	git := &MockGitter{branch: "zomg"}
+	vs := gitsemver.GitSemVer{Git: git, Env: env}
+
+	name, err := vs.GetPushBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "zomg", name)
+
+	git.branch = "detached"
+	env["CI_MERGE_REQUEST_TARGET_BRANCH_NAME"] = "main"
+	env["GITHUB_BASE_REF"] = "main"
+	name, err = vs.GetPushBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "", name)
+
+	env["GITHUB_REF_TYPE"] = "branch"
+	env["GITHUB_REF_NAME"] = "feature/foo"
+	name, err = vs.GetPushBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "feature/foo", name)
+
+	env["CI_COMMIT_BRANCH"] = "feature/bar"
+	name, err = vs.GetPushBranch(".")
+	isEqual(t, err, nil)
+	isEqual(t, "feature/bar", name)
+}
+
 func Test_VersionStringer_GetVersionFromBranchRef_GitHub(t *testing.T) {
 	env := MockEnvironment{
+		"GITHUB_ACTIONS":    "true",
 		"GITHUB_REF_TYPE":   "branch",
 		"GITHUB_REF_NAME":   "feature/foo",
 		"GITHUB_RUN_NUMBER": "789",
@@ -318,7 +447,7 @@ func Test_VersionStringer_GetVersionFromBranchRef_GitHub(t *testing.T) {
 }
 
 func Test_VersionStringer_GetBranchFromTag_GitHub_NoContainingBranch(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true"}
 	git := &MockGitter{}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -331,7 +460,7 @@ func Test_VersionStringer_GetBranchFromTag_GitHub_NoContainingBranch(t *testing.
 }
 
 func Test_VersionStringer_GetBranchFromTag_GitLab_NoReleaseBranch(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITLAB_CI": "true"}
 	git := &MockGitter{}
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
 
@@ -366,7 +495,7 @@ func Test_VersionStringer_GetBuild(t *testing.T) {
 }
 
 func Test_VersionStringer_GetVersion(t *testing.T) {
-	env := MockEnvironment{}
+	env := MockEnvironment{"GITHUB_ACTIONS": "true", "GITLAB_CI": "true"}
 	git := &MockGitter{}
 
 	vs := gitsemver.GitSemVer{Git: git, Env: env}
@@ -490,6 +619,83 @@ func Test_VersionStringer_GetTag_ClosestTagNotOverriddenByUnreachableSameTreeTag
 	}
 }
 
+func Test_VersionStringer_GetTag_NearestTag(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		setup    func(t *testing.T, repo string)
+		want     string
+		describe bool // want is what "git describe" gives
+	}{
+		{"lightweight tags on one commit", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v1.3.0")
+			runGit(t, repo, nil, "tag", "v1.3")
+			commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+		}, "v1.3", true},
+		{"annotated tag before lightweight", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v1.10.0")
+			runGit(t, repo, nil, "tag", "-a", "-m", "annotated", "v1.9.0")
+			commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+		}, "v1.9.0", true},
+		{"non-semver tags are skipped", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "1foo")
+		}, "v0.0.0", false},
+		{"non-semver tag at HEAD is skipped", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v1.0.0")
+			commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+			runGit(t, repo, nil, "tag", "1foo")
+		}, "v1.0.0", false},
+		{"many non-semver tags are skipped", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v1.0.0")
+			for i := 0; i < 20; i++ {
+				commitAt(t, repo, "a.txt", strconv.Itoa(i)+"\n", "c", "2020-01-02T00:00:00Z")
+				runGit(t, repo, nil, "tag", strconv.Itoa(i)+"foo")
+			}
+		}, "v1.0.0", false},
+		{"only reachable tags count", func(t *testing.T, repo string) {
+			commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v1.0.0")
+			runGit(t, repo, nil, "checkout", "-q", "-b", "feature")
+			commitAt(t, repo, "a.txt", "a\nb\n", "c2", "2020-01-02T00:00:00Z")
+			runGit(t, repo, nil, "checkout", "-q", "-b", "other", "HEAD~1")
+			commitAt(t, repo, "a.txt", "a\nc\n", "c3", "2020-01-03T00:00:00Z")
+			runGit(t, repo, nil, "tag", "v9.0.0")
+			runGit(t, repo, nil, "checkout", "-q", "feature")
+		}, "v1.0.0", true},
+	} {
+		for _, gitBin := range []string{"git", gitsemver.GitInternal} {
+			t.Run(tc.name+"/"+gitBin, func(t *testing.T) {
+				repo := t.TempDir()
+				runGit(t, repo, nil, "init", "-q")
+				runGit(t, repo, nil, "config", "user.email", "test@example.com")
+				runGit(t, repo, nil, "config", "user.name", "Test")
+				tc.setup(t, repo)
+				if tc.describe {
+					isEqual(t, tc.want, runGit(t, repo, nil, "describe", "--tags", "--abbrev=0"))
+				}
+				var buf bytes.Buffer
+				vs, err := gitsemver.New(gitBin, &buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tag, _, err := vs.GetTag(repo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				isEqual(t, tc.want, tag)
+				// one walk over the history, however many tags there are
+				if n := strings.Count(buf.String(), " rev-list "); n > 1 {
+					t.Errorf("expected at most 1 rev-list call, got %d\nlog:\n%s", n, buf.String())
+				}
+			})
+		}
+	}
+}
+
 func Test_VersionStringer_GetTag_PicksHighestMixedPrefixTagOnSameTree(t *testing.T) {
 	repo := t.TempDir()
 	runGit(t, repo, nil, "init", "-q")
@@ -543,13 +749,335 @@ func Test_VersionStringer_GetTag_BatchesTagHashLookup(t *testing.T) {
 		t.Fatalf("expected sameTree true, got false")
 	}
 
-	revParseCalls := 0
+	revParseCalls, forEachRefCalls := 0, 0
 	for _, line := range strings.Split(buf.String(), "\n") {
 		if strings.Contains(line, " rev-parse ") {
 			revParseCalls++
 		}
+		if strings.Contains(line, " for-each-ref ") {
+			forEachRefCalls++
+		}
+	}
+	if revParseCalls != 1 || forEachRefCalls != 1 {
+		t.Fatalf("expected 1 rev-parse call (HEAD) and 1 for-each-ref call, got %d and %d\nlog:\n%s", revParseCalls, forEachRefCalls, buf.String())
+	}
+}
+
+func Test_VersionStringer_GetBump(t *testing.T) {
+	env := MockEnvironment{}
+	git := &MockGitter{messages: []string{"chore: tidy", "fix: bug"}}
+	vs := gitsemver.GitSemVer{Git: git, Env: env}
+
+	bump, err := vs.GetBump(".", "v4.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, gitsemver.BumpPatch, bump)
+	isEqual(t, "commit-4", git.messagesFrom)
+
+	git.messages = append(git.messages, "feat: thing")
+	bump, err = vs.GetBump(".", "v0.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, gitsemver.BumpMinor, bump)
+	isEqual(t, "", git.messagesFrom)
+
+	vs.BumpRules = gitsemver.BumpRules{"chore": gitsemver.BumpMajor}
+	if bump, err = vs.GetBump(".", "v0.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, gitsemver.BumpMajor, bump)
+}
+
+func Test_VersionStringer_GetVersion_Hashes(t *testing.T) {
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	git := &MockGitter{commitTime: when}
+	vs := gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}}
+	vi, err := vs.GetVersion(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, when, vi.CommitTime)
+	isEqual(t, "v6.0.0", vi.Tag)
+	isEqual(t, "commit-7", vi.Commit)
+	isEqual(t, "tree-7", vi.Tree)
+	isEqual(t, "commit-6", vi.TagCommit)
+	isEqual(t, false, vi.Dirty)
+
+	git.dirty = true
+	vs = gitsemver.GitSemVer{Git: git, Env: MockEnvironment{"CI_COMMIT_TAG": "v6.0.0"}}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, true, vi.Dirty)
+	isEqual(t, "commit-7", vi.Commit)
+
+	vs = gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}, NoCommitTime: true}
+	if vi, err = vs.GetVersion("."); err != nil {
+		t.Fatal(err)
+	}
+	isTrue(t, vi.CommitTime.IsZero())
+	isEqual(t, "commit-7", vi.Commit)
+}
+
+func Test_VersionStringer_GetTag_Prereleases(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.2.3")
+	commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.3.0-rc.1")
+
+	for _, tc := range []struct {
+		prereleases bool
+		wantTag     string
+		wantSame    bool
+	}{
+		{false, "v1.2.3", false},
+		{true, "v1.3.0-rc.1", true},
+	} {
+		vs, err := gitsemver.New("git", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs.Prereleases = tc.prereleases
+		tag, sameTree, err := vs.GetTag(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isEqual(t, tc.wantTag, tag)
+		isEqual(t, tc.wantSame, sameTree)
+	}
+
+	// a prerelease tag still resolves
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := vs.ResolveVersion(repo, "v1.3.0-rc.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, 1, len(matches))
+	isEqual(t, runGit(t, repo, nil, "rev-parse", "HEAD"), matches[0].Commit)
+}
+
+func Test_VersionStringer_GetTag_TreePath(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	if err := os.MkdirAll(filepath.Join(repo, "tools", "cli"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, repo, "tools/cli/main.go", "package main\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "tools/cli/v1.0.0")
+	commitAt(t, repo, "other.txt", "sibling change\n", "c2", "2020-01-02T00:00:00Z")
+
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs.TagPrefix = "tools/cli/"
+	tag, sameTree, err := vs.GetTag(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "tools/cli/v1.0.0", tag)
+	isEqual(t, false, sameTree)
+
+	vs, err = gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs.TagPrefix = "tools/cli/"
+	vs.TreePath = "tools/cli"
+	tag, sameTree, err = vs.GetTag(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "tools/cli/v1.0.0", tag)
+	isEqual(t, true, sameTree)
+
+	commitAt(t, repo, "tools/cli/main.go", "package main // changed\n", "c3", "2020-01-03T00:00:00Z")
+	vs, err = gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs.TagPrefix = "tools/cli/"
+	vs.TreePath = "tools/cli"
+	if _, sameTree, err = vs.GetTag(repo); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, false, sameTree)
+}
+
+func Test_VersionStringer_Refresh(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "1\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs.Env = MockEnvironment{}
+	tag, _, err := vs.GetTag(repo)
+	isEqual(t, err, nil)
+	isEqual(t, tag, "v1.0.0")
+
+	runGit(t, repo, nil, "tag", "v1.0.1")
+	tag, _, err = vs.GetTag(repo)
+	isEqual(t, err, nil)
+	isEqual(t, tag, "v1.0.0")
+
+	vs.Refresh()
+	tag, _, err = vs.GetTag(repo)
+	isEqual(t, err, nil)
+	isEqual(t, tag, "v1.0.1")
+}
+
+func Test_VersionStringer_GetVersionAt(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "branch", "-M", "main")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "1\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "a.txt", "2\n", "c2", "2020-01-02T00:00:00Z")
+	commitAt(t, repo, "a.txt", "3\n", "c3", "2020-01-03T00:00:00Z")
+	runGit(t, repo, nil, "tag", "-a", "-m", "v1.1.0", "v1.1.0")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "feature")
+	commitAt(t, repo, "a.txt", "4\n", "c4", "2020-01-04T00:00:00Z")
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("dirty\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		rev      string
+		version  string
+		branch   string
+		sameTree bool
+	}{
+		{"v1.0.0", "v1.0.0", "main", true},
+		{"main", "v1.1.0", "main", true},
+		{"main~1", "v1.0.0-main.2", "main", false},
+		{"feature", "v1.1.0-feature.4", "feature", false},
+	} {
+		vs, err := gitsemver.New("git", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vi, err := vs.GetVersionAt(repo, tt.rev)
+		if err != nil {
+			t.Fatal(tt.rev, err)
+		}
+		commit := strings.TrimSpace(runGit(t, repo, nil, "rev-parse", tt.rev+"^{commit}"))
+		if vi.Version() != tt.version || vi.Branch != tt.branch || vi.SameTree != tt.sameTree || vi.Dirty || vi.Commit != commit {
+			t.Errorf("GetVersionAt(%q) = %q branch %q sameTree %v dirty %v commit %s, want %q branch %q sameTree %v commit %s",
+				tt.rev, vi.Version(), vi.Branch, vi.SameTree, vi.Dirty, vi.Commit, tt.version, tt.branch, tt.sameTree, commit)
+		}
+	}
+
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vi, err := vs.GetVersion(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, true, vi.Dirty)
+	if _, err = vs.GetVersionAt(repo, "nosuchrev"); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
+
+func Test_VersionStringer_GetVersions(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "branch", "-M", "main")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "1\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "a.txt", "2\n", "c2", "2020-01-02T00:00:00Z")
+	commitAt(t, repo, "a.txt", "3\n", "c3", "2020-01-03T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.1.0")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "feature")
+	commitAt(t, repo, "a.txt", "4\n", "c4", "2020-01-04T00:00:00Z")
+	commitAt(t, repo, "a.txt", "5\n", "c5", "2020-01-05T00:00:00Z")
+
+	var buf bytes.Buffer
+	vs, err := gitsemver.New("git", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := vs.GetVersions(repo, "v1.0.0..feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for i := range versions {
+		got = append(got, versions[i].Version())
+	}
+	want := []string{"v1.1.0-feature.5", "v1.1.0-feature.4", "v1.1.0-feature.3", "v1.0.0-feature.2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("GetVersions = %q, want %q", got, want)
+	}
+	// the history and the branch are only read once for the whole range
+	if n := strings.Count(buf.String(), " rev-list "); n != 2 {
+		t.Errorf("expected 2 rev-list calls, got %d\nlog:\n%s", n, buf.String())
+	}
+	if n := strings.Count(buf.String(), " branch "); n != 1 {
+		t.Errorf("expected 1 branch call, got %d\nlog:\n%s", n, buf.String())
+	}
+
+	// each commit gets the version describe gives it
+	for _, gitBin := range []string{"git", gitsemver.GitInternal} {
+		for _, mode := range []gitsemver.BuildMode{gitsemver.BuildAll, gitsemver.BuildHeight} {
+			vsAll, err := gitsemver.New(gitBin, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vsAll.BuildMode = mode
+			all, err := vsAll.GetVersions(repo, "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+			isEqual(t, 3, len(all))
+			for i := range all {
+				vsAt, err := gitsemver.New(gitBin, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				vsAt.BuildMode = mode
+				vi, err := vsAt.GetVersionAt(repo, all[i].Commit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				isEqual(t, vi.Version(), all[i].Version())
+				isTrue(t, vi.CommitTime.Equal(all[i].CommitTime))
+			}
+		}
+	}
+
+	forEachRefCalls := strings.Count(buf.String(), " for-each-ref ")
+	if forEachRefCalls != 1 {
+		t.Errorf("expected 1 for-each-ref call, got %d\nlog:\n%s", forEachRefCalls, buf.String())
+	}
+
+	if versions, err = vs.GetVersions(repo, "feature..feature"); err != nil || len(versions) != 0 {
+		t.Errorf("empty range = %v, %v", versions, err)
 	}
-	if revParseCalls != 2 {
-		t.Fatalf("expected 2 rev-parse calls (HEAD + batch), got %d\nlog:\n%s", revParseCalls, buf.String())
+	if _, err = vs.GetVersions(repo, "nosuchrev..feature"); err == nil {
+		t.Error("expected an error for an unknown revision")
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //go:generate go run github.com/linkdata/gitsemver@latest -gopackage -out internal/gitsemver/version.gen.go
@@ -21,38 +22,74 @@ type Gitter interface {
 	Exec(args ...string) (output []byte, err error)
 	// CheckGitRepo checks that the given directory is part of a git repository.
 	CheckGitRepo(dir string) (repo string, err error)
-	// GetTags returns all tags, sorted by version descending.
-	GetTags(repo string) (tags []string, err error)
+	// GetTags returns all tags that are the prefix followed by a semver version, sorted by version descending.
+	GetTags(repo, prefix string) (tags []string, err error)
+	// GetTagHashes returns the tags that are the prefix followed by a semver version with their
+	// commit and root tree hashes, sorted by version descending. Tags not pointing to commits are left out.
+	GetTagHashes(repo, prefix string) (tags []GitTag, err error)
 	// GetCurrentTreeHash returns the current tree hash.
 	GetCurrentTreeHash(repo string) (string, error)
 	// GetHashes returns the target commit and tree hashes for the given tag.
 	GetHashes(repo, tag string) (commit string, tree string, err error)
 	// GetHashesBatch returns commit/tree hashes for many tags.
 	GetHashesBatch(repo string, tags []string) (hashes []GitTag, err error)
-	// GetClosestTag returns the closest semver tag for the given commit hash.
-	GetClosestTag(repo, commit string) (tag string, err error)
+	// GetPathHashes is like GetHashes, but returns the hash of the given path
+	// in the tag instead of the root tree hash. If the path doesn't exist in
+	// the tag, the empty tree hash is returned. An empty path means the root tree.
+	GetPathHashes(repo, tag, path string) (commit string, tree string, err error)
+	// GetPathHashesBatch is like GetHashesBatch, but for the given path as in GetPathHashes.
+	GetPathHashesBatch(repo string, tags []string, path string) (hashes []GitTag, err error)
+	// GetNearestTag returns the tag among tags that is nearest to the given commit by ancestry,
+	// and the number of commits reachable from the commit but not from the tag. Equal distances
+	// are won by the tag on the newer commit, and tags on the same commit by the first in tags.
+	// Returns an empty tag if none of them are reachable from the commit.
+	GetNearestTag(repo string, tags []GitTag, commit string) (tag string, distance int, err error)
 	// GetBranch returns the current branch in the repository or an empty string.
 	GetBranch(repo string) (branch string, err error)
 	// GetBranchesFromTag returns the non-HEAD branches in the repository that have the tag, otherwise an empty string.
 	GetBranchesFromTag(repo, tag string) (branches []string, err error)
+	// GetBranchesContaining returns all the non-HEAD branches in the repository that have the given revision.
+	GetBranchesContaining(repo, rev string) (branches []string, err error)
 	// GetBuild returns the number of commits in the currently checked out branch as a string, or an empty string
 	GetBuild(repo string) (string, error)
+	// CountCommits returns the number of commits reachable from rev but not from since,
+	// only counting commits that touch path. Empty since or path mean no such restriction.
+	CountCommits(repo, rev, since, path string) (count int, err error)
+	// GetCommitMessages returns the full commit messages (subject and body) of the
+	// commits reachable from to but not from from, newest first. If from is empty,
+	// all commits reachable from to are returned.
+	GetCommitMessages(repo, from, to string) (messages []string, err error)
+	// GetCommits returns the commits in the revision range, like "A..B", children
+	// before their parents. Revisions and "git rev-list" options in the range are
+	// separated by spaces.
+	GetCommits(repo, revRange string) (commits []string, err error)
+	// GetCommitTime returns the committer time of the given revision.
+	GetCommitTime(repo, rev string) (when time.Time, err error)
 	// GetHead returns the current HEAD commit hash if skip is false.
 	GetHead(repo string, skip bool) (head string, err error)
 	// ResetHard hard-resets the repository to the given commit. Does nothing if commit is empty.
 	ResetHard(repo, commit string) (err error)
-	// FetchTags calls "git fetch --tags". Uses the "--unshallow" option if needed.
-	FetchTags(repo string) error
+	// FetchTags calls "git fetch --tags" for the given remote, or the default
+	// remote if it's empty. Uses the "--unshallow" option if needed.
+	FetchTags(repo, remote string) error
 	// CreateTag creates a new tag. If tag.gpgSign is true, it creates
 	// a signed annotated tag with a generated message; otherwise it creates
 	// a lightweight tag. Does nothing if tag is empty.
 	CreateTag(repo, tag string) error
 	// DeleteTag deletes the given tag. Does nothing if tag is empty.
 	DeleteTag(repo, tag string) (err error)
-	// PushTag pushes the given tag to the origin. Does nothing if tag is empty.
-	PushTag(repo, tag string) (err error)
-	// DeleteRemoteTag deletes the given tag from origin. Does nothing if tag is empty.
-	DeleteRemoteTag(repo, tag string) (err error)
+	// PushTag pushes the given tag to the remote, or "origin" if remote is empty.
+	// If branch is not empty, HEAD is pushed to it in the same atomic push, so
+	// either both refs are updated or neither is. Does nothing if tag is empty.
+	PushTag(repo, remote, tag, branch string) (err error)
+	// UnpushTag undoes PushTag on the remote, or "origin" if remote is empty. It
+	// deletes the tag and, if branch is not empty, moves the branch back to commit,
+	// in one atomic push. Fails without changing anything if the remote no longer
+	// has the tag or branch where PushTag left them. Does nothing if tag is empty.
+	UnpushTag(repo, remote, tag, branch, commit string) (err error)
+	// FastForward fetches the given branch from the remote, or "origin" if remote
+	// is empty, and fast-forwards HEAD to it. Fails if HEAD has diverged from it.
+	FastForward(repo, remote, branch string) (err error)
 	// Commit commits the given file if it exists. Does nothing if filePath is empty.
 	Commit(repo, filePath, tag string) (err error)
 	// CleanStatus returns true if there are no uncommitted changes in the repo.
@@ -63,6 +100,7 @@ type Gitter interface {
 type DefaultGitter struct {
 	Git      string
 	DebugOut io.Writer
+	CatFile  *CatFile // if not nil, hash, commit and history lookups use its long-lived cat-file processes
 }
 
 const revParseBatchTagCount = 128
@@ -74,10 +112,16 @@ func MaybeSync(w io.Writer) {
 }
 
 func (dg DefaultGitter) Exec(args ...string) (output []byte, err error) {
+	return dg.execInput(nil, args...)
+}
+
+// execInput is like Exec, but reads the standard input of git from input.
+func (dg DefaultGitter) execInput(input io.Reader, args ...string) (output []byte, err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 	var sout, serr bytes.Buffer
 	cmd := exec.Command(dg.Git, args...) /* #nosec G204 */
+	cmd.Stdin = input
 	cmd.Stdout = &sout
 	cmd.Stderr = &serr
 	if dg.DebugOut != nil {
@@ -108,6 +152,14 @@ func (dg DefaultGitter) Exec(args ...string) (output []byte, err error) {
 	return
 }
 
+// Close stops the cat-file processes, if any.
+func (dg DefaultGitter) Close() (err error) {
+	if dg.CatFile != nil {
+		err = dg.CatFile.Close()
+	}
+	return
+}
+
 func NewDefaultGitter(gitBin string, debugOut io.Writer) (gitter Gitter, err error) {
 	if gitBin, err = exec.LookPath(gitBin); err == nil {
 		gitter = DefaultGitter{Git: gitBin, DebugOut: debugOut}
@@ -169,20 +221,59 @@ func (dg DefaultGitter) CheckGitRepo(dir string) (repo string, err error) {
 	return
 }
 
-// GetTags returns all tags, sorted by version descending.
-// The latest tag is the first in the list.
-func (dg DefaultGitter) GetTags(repo string) (tags []string, err error) {
+// GetTags returns all tags that are the prefix followed by a semver version,
+// sorted by version descending. The latest tag is the first in the list.
+func (dg DefaultGitter) GetTags(repo, prefix string) (tags []string, err error) {
 	var b []byte
-	if b, err = dg.Exec("-C", repo, "tag", "--sort=-v:refname", "--list", "v[0-9]*", "[0-9]*"); len(b) > 0 /* #nosec G204 */ {
+	pattern := escapeGlob(prefix)
+	if b, err = dg.Exec("-C", repo, "tag", "--sort=-v:refname", "--list", pattern+"v[0-9]*", pattern+"[0-9]*"); len(b) > 0 /* #nosec G204 */ {
 		for _, tag := range strings.Split(string(b), "\n") {
-			if tag = strings.TrimSpace(tag); tag != "" && isSemverTag(tag) {
+			if tag = strings.TrimSpace(tag); tag != "" && isPrefixedSemverTag(prefix, tag) {
 				tags = append(tags, tag)
 			}
 		}
 		// Git's multi-pattern listing can interleave v-prefixed and non-prefixed
 		// tags in a way that is not globally version-sorted. Normalize here.
 		sort.SliceStable(tags, func(i, j int) bool {
-			return semverTagGreater(tags[i], tags[j])
+			return semverTagGreater(tags[i][len(prefix):], tags[j][len(prefix):])
+		})
+	}
+	return
+}
+
+// tagHashesFormat is the for-each-ref format for GetTagHashes. The fields
+// starting with "*" are those of the tagged object for annotated tags.
+const tagHashesFormat = "%(refname:strip=2)%00%(objecttype)%00%(objectname)%00%(tree)%00%(*objecttype)%00%(*objectname)%00%(*tree)"
+
+// GetTagHashes returns the tags that are the prefix followed by a semver version with their
+// commit and root tree hashes, sorted by version descending, using a single "for-each-ref" call.
+// Tags of tags are resolved with GetHashes, and tags not pointing to commits are left out.
+func (dg DefaultGitter) GetTagHashes(repo, prefix string) (tags []GitTag, err error) {
+	var b []byte
+	pattern := "refs/tags/" + escapeGlob(prefix)
+	if b, err = dg.Exec("-C", repo, "for-each-ref", "--format="+tagHashesFormat, pattern+"v[0-9]*", pattern+"[0-9]*"); err == nil /* #nosec G204 */ {
+		for _, line := range strings.Split(string(b), "\n") {
+			fields := strings.Split(line, "\x00")
+			if len(fields) != 7 || !isPrefixedSemverTag(prefix, fields[0]) {
+				continue
+			}
+			gt := GitTag{Tag: fields[0], Annotated: fields[1] == "tag"}
+			switch {
+			case fields[1] == "commit":
+				gt.Commit, gt.Tree = fields[2], fields[3]
+			case fields[4] == "commit":
+				gt.Commit, gt.Tree = fields[5], fields[6]
+			case fields[4] == "tag":
+				gt.Commit, gt.Tree, _ = dg.GetHashes(repo, gt.Tag)
+			}
+			if gt.Commit != "" && gt.Tree != "" {
+				tags = append(tags, gt)
+			}
+		}
+		// equal versions are ordered like "tag --sort=-v:refname" does in GetTags
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Tag > tags[j].Tag })
+		sort.SliceStable(tags, func(i, j int) bool {
+			return semverTagGreater(tags[i].Tag[len(prefix):], tags[j].Tag[len(prefix):])
 		})
 	}
 	return
@@ -199,6 +290,12 @@ func (dg DefaultGitter) GetCurrentTreeHash(repo string) (hash string, err error)
 
 // GetHashes returns the target commit and tree hashes for the given tag.
 func (dg DefaultGitter) GetHashes(repo, tag string) (commit, tree string, err error) {
+	if dg.CatFile != nil {
+		// missing tags are left to rev-parse for its error message
+		if hashes, e := dg.catFileHashes(repo, []string{tag}, ""); e == nil && len(hashes) == 1 {
+			return hashes[0].Commit, hashes[0].Tree, nil
+		}
+	}
 	var b []byte
 	if b, err = dg.Exec("-C", repo, "rev-parse", tag+"^{commit}", tag+"^{tree}"); err == nil && len(b) > 0 /* #nosec G204 */ {
 		hashes := strings.Split(strings.TrimSpace(string(b)), "\n")
@@ -210,29 +307,36 @@ func (dg DefaultGitter) GetHashes(repo, tag string) (commit, tree string, err er
 }
 
 // GetHashesBatch returns commit/tree hashes for many tags using chunked
-// rev-parse calls to reduce process startup overhead. If any chunk fails,
-// iteration stops and the returned slice is nil.
+// rev-parse calls to reduce process startup overhead. A tag is annotated if
+// it doesn't name its commit directly. If any chunk fails, iteration stops
+// and the returned slice is nil.
 func (dg DefaultGitter) GetHashesBatch(repo string, tags []string) (hashes []GitTag, err error) {
+	if dg.CatFile != nil {
+		if hashes, err = dg.catFileHashes(repo, tags, ""); err == nil {
+			return
+		}
+	}
 	hashes = make([]GitTag, 0, len(tags))
 	for i := 0; err == nil && i < len(tags); i += revParseBatchTagCount {
 		end := min(i+revParseBatchTagCount, len(tags))
 		chunk := tags[i:end]
-		args := make([]string, 0, 3+len(chunk)*2)
+		args := make([]string, 0, 3+len(chunk)*3)
 		args = append(args, "-C", repo, "rev-parse")
 		for _, tag := range chunk {
-			args = append(args, tag+"^{commit}", tag+"^{tree}")
+			args = append(args, tag, tag+"^{commit}", tag+"^{tree}")
 		}
 		var b []byte
 		if b, err = dg.Exec(args...); err == nil {
 			lines := strings.Fields(string(b))
-			if len(lines) == len(chunk)*2 {
+			if len(lines) == len(chunk)*3 {
 				for idx, tag := range chunk {
-					commit, tree := lines[idx*2], lines[idx*2+1]
+					object, commit, tree := lines[idx*3], lines[idx*3+1], lines[idx*3+2]
 					if commit != "" && tree != "" {
 						hashes = append(hashes, GitTag{
-							Tag:    tag,
-							Commit: commit,
-							Tree:   tree,
+							Tag:       tag,
+							Commit:    commit,
+							Tree:      tree,
+							Annotated: object != commit,
 						})
 					}
 				}
@@ -247,42 +351,106 @@ func (dg DefaultGitter) GetHashesBatch(repo string, tags []string) (hashes []Git
 	return
 }
 
-// GetClosestTag returns the closest semver tag for the given commit hash.
-func (dg DefaultGitter) GetClosestTag(repo, commit string) (tag string, err error) {
-	var listed []byte
-	if listed, err = dg.Exec("-C", repo, "tag", "--merged", commit, "--list", "v[0-9]*", "[0-9]*"); err == nil {
-		candidates := map[string]struct{}{}
-		for _, listedTag := range strings.Fields(string(listed)) {
-			candidates[listedTag] = struct{}{}
-		}
+// emptyTreeHash returns the hash of the empty tree in the object format of the given hash.
+func emptyTreeHash(hash string) string {
+	if len(hash) == 64 {
+		return "6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321"
+	}
+	return "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+}
 
-		if len(candidates) > 0 {
-			seen := map[string]struct{}{}
-			for i := 0; err == nil && i < len(candidates); i++ {
-				// Ask git for the closest tag by ancestry. If it returns a non-strict
-				// semver tag (for example "1foo"), exclude it and try again.
-				args := []string{
-					"-C", repo,
-					"describe", "--tags", "--abbrev=0",
-					"--match=v[0-9]*",
-					"--match=[0-9]*",
-				}
-				for pattern := range seen {
-					args = append(args, "--exclude="+pattern)
-				}
-				args = append(args, commit)
-				var b []byte
-				if b, err = dg.Exec(args...); err == nil {
-					candidate := strings.TrimSpace(string(b))
-					if candidate == "" || isSemverTag(candidate) {
-						tag = candidate
-						return
+// parseBatchCheck returns the object name from a "cat-file --batch-check" output line,
+// or an empty string if the object is missing or ambiguous.
+func parseBatchCheck(line string) (hash string) {
+	if !strings.HasSuffix(line, " missing") && !strings.HasSuffix(line, " ambiguous") {
+		hash, _, _ = strings.Cut(line, " ")
+	}
+	return
+}
+
+// GetPathHashes returns the target commit hash for the given tag and the hash of the path in it.
+// If the path doesn't exist in the tag, the empty tree hash is returned. An empty path means the root tree.
+func (dg DefaultGitter) GetPathHashes(repo, tag, path string) (commit, tree string, err error) {
+	if path == "" {
+		return dg.GetHashes(repo, tag)
+	}
+	var hashes []GitTag
+	if hashes, err = dg.GetPathHashesBatch(repo, []string{tag}, path); err == nil && len(hashes) == 1 {
+		commit, tree = hashes[0].Commit, hashes[0].Tree
+	}
+	return
+}
+
+// GetPathHashesBatch returns the commit hashes and path hashes for many tags using a single
+// "cat-file --batch-check" call. Tags that don't exist are left out.
+func (dg DefaultGitter) GetPathHashesBatch(repo string, tags []string, path string) (hashes []GitTag, err error) {
+	if path == "" {
+		return dg.GetHashesBatch(repo, tags)
+	}
+	if dg.CatFile != nil {
+		if hashes, err = dg.catFileHashes(repo, tags, path); err == nil {
+			return
+		}
+	}
+	hashes = make([]GitTag, 0, len(tags))
+	if len(tags) > 0 {
+		var input strings.Builder
+		for _, tag := range tags {
+			fmt.Fprintf(&input, "%s^{commit}\n%s:%s\n%s\n", tag, tag, path, tag)
+		}
+		var b []byte
+		if b, err = dg.execInput(strings.NewReader(input.String()), "-C", repo, "cat-file", "--batch-check=%(objectname) %(objecttype)"); err == nil /* #nosec G204 */ {
+			lines := strings.Split(string(b), "\n")
+			if len(lines) == len(tags)*3 {
+				for idx, tag := range tags {
+					if commit := parseBatchCheck(lines[idx*3]); commit != "" {
+						tree := parseBatchCheck(lines[idx*3+1])
+						if tree == "" {
+							tree = emptyTreeHash(commit)
+						}
+						hashes = append(hashes, GitTag{
+							Tag:       tag,
+							Commit:    commit,
+							Tree:      tree,
+							Annotated: strings.HasSuffix(lines[idx*3+2], " tag"),
+						})
 					}
-					seen[candidate] = struct{}{}
 				}
+			} else {
+				err = NewErrUnexpectedRevParseOutput(len(tags), len(lines))
 			}
 		}
 	}
+	if err != nil {
+		hashes = nil
+	}
+	return
+}
+
+// GetNearestTag returns the tag among tags that is nearest to the given commit by
+// ancestry using a single "rev-list" call, and the number of commits reachable from
+// the commit but not from the tag. Returns an empty tag if none of them are reachable.
+func (dg DefaultGitter) GetNearestTag(repo string, tags []GitTag, commit string) (tag string, distance int, err error) {
+	if len(tags) > 0 {
+		var g *commitGraph
+		if g, err = dg.commitGraph(repo, commit); err == nil {
+			tag, distance = g.nearestTag(g.head(), tags)
+		}
+	}
+	return
+}
+
+// commitGraph returns the history reachable from rev.
+func (dg DefaultGitter) commitGraph(repo, rev string) (g *commitGraph, err error) {
+	if dg.CatFile != nil {
+		if g, e := dg.catFileGraph(repo, rev); e == nil && g != nil {
+			return g, nil
+		}
+	}
+	var b []byte
+	if b, err = dg.Exec("-C", repo, "rev-list", "--topo-order", "--parents", "--timestamp", rev, "--"); err == nil /* #nosec G204 */ {
+		g, err = parseRevList(string(b))
+	}
 	return
 }
 
@@ -296,8 +464,19 @@ func LastName(s string) string {
 func (dg DefaultGitter) GetBranchesFromTag(repo, tag string) (branches []string, err error) {
 	tag = strings.TrimPrefix(tag, "refs/")
 	tag = strings.TrimPrefix(tag, "tags/")
+	return dg.branchesContaining(repo, "tags/"+tag, true)
+}
+
+func (dg DefaultGitter) GetBranchesContaining(repo, rev string) (branches []string, err error) {
+	return dg.branchesContaining(repo, rev, false)
+}
+
+// branchesContaining returns the non-HEAD branches that have rev, with remote
+// branches named without the remote. If preferCurrent is true and the
+// current branch is one of them, only it is returned.
+func (dg DefaultGitter) branchesContaining(repo, rev string, preferCurrent bool) (branches []string, err error) {
 	var b []byte
-	if b, err = dg.Exec("-C", repo, "branch", "--all", "--no-color", "--contains", "tags/"+tag); len(b) > 0 /* #nosec G204 */ {
+	if b, err = dg.Exec("-C", repo, "branch", "--all", "--no-color", "--contains", rev); len(b) > 0 /* #nosec G204 */ {
 		seen := map[string]struct{}{}
 		for _, s := range strings.Split(string(b), "\n") {
 			if s = strings.TrimSpace(s); len(s) > 1 {
@@ -318,7 +497,7 @@ func (dg DefaultGitter) GetBranchesFromTag(repo, tag string) (branches []string,
 								seen[s] = struct{}{}
 								branches = append(branches, s)
 							}
-							if starred {
+							if starred && preferCurrent {
 								branches = branches[len(branches)-1:]
 								break
 							}
@@ -340,6 +519,11 @@ func (dg DefaultGitter) GetBranch(repo string) (branch string, err error) {
 }
 
 func (dg DefaultGitter) GetBuild(repo string) (buildnum string, err error) {
+	if dg.CatFile != nil {
+		if g, e := dg.catFileGraph(repo, "HEAD"); e == nil && g != nil {
+			return strconv.Itoa(len(g.order)), nil
+		}
+	}
 	var b []byte
 	if b, err = dg.Exec("-C", repo, "rev-list", "HEAD", "--count"); err == nil && len(b) > 0 /* #nosec G204 */ {
 		str := strings.TrimSpace(string(b))
@@ -351,8 +535,77 @@ func (dg DefaultGitter) GetBuild(repo string) (buildnum string, err error) {
 	return
 }
 
+func (dg DefaultGitter) CountCommits(repo, rev, since, path string) (count int, err error) {
+	if dg.CatFile != nil && path == "" {
+		if commits, e := dg.catFileRange(repo, since, rev); e == nil && commits != nil {
+			return len(commits), nil
+		}
+	}
+	args := []string{"-C", repo, "rev-list", "--count", rev}
+	if since != "" {
+		args = append(args, "^"+since)
+	}
+	args = append(args, "--")
+	if path != "" {
+		args = append(args, path)
+	}
+	var b []byte
+	if b, err = dg.Exec(args...); err == nil /* #nosec G204 */ {
+		count, err = strconv.Atoi(strings.TrimSpace(string(b)))
+	}
+	return
+}
+
+func (dg DefaultGitter) GetCommitMessages(repo, from, to string) (messages []string, err error) {
+	if dg.CatFile != nil {
+		if commits, e := dg.catFileRange(repo, from, to); e == nil && commits != nil {
+			return commitMessages(commits), nil
+		}
+	}
+	revRange := to
+	if from != "" {
+		revRange = from + ".." + to
+	}
+	var b []byte
+	if b, err = dg.Exec("-C", repo, "log", "--format=%B%x00", revRange, "--"); err == nil /* #nosec G204 */ {
+		for _, msg := range strings.Split(string(b), "\x00") {
+			if msg = strings.TrimSpace(msg); msg != "" {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return
+}
+
+func (dg DefaultGitter) GetCommits(repo, revRange string) (commits []string, err error) {
+	args := append([]string{"-C", repo, "rev-list", "--topo-order"}, strings.Fields(revRange)...)
+	var b []byte
+	if b, err = dg.Exec(append(args, "--")...); err == nil /* #nosec G204 */ {
+		commits = strings.Fields(string(b))
+	}
+	return
+}
+
+func (dg DefaultGitter) GetCommitTime(repo, rev string) (when time.Time, err error) {
+	if dg.CatFile != nil {
+		if c, e := dg.catFileCommit(repo, rev); e == nil && c != nil {
+			return c.when, nil
+		}
+	}
+	var b []byte
+	if b, err = dg.Exec("-C", repo, "log", "-1", "--format=%cI", rev, "--"); err == nil /* #nosec G204 */ {
+		when, err = time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	}
+	return
+}
+
 func (dg DefaultGitter) GetHead(repo string, skip bool) (head string, err error) {
 	if !skip {
+		if dg.CatFile != nil {
+			if results, e := dg.catFileInfo(repo, "HEAD"); e == nil && results[0].hash != "" {
+				return results[0].hash, nil
+			}
+		}
 		var b []byte
 		if b, err = dg.Exec("-C", repo, "rev-parse", "HEAD"); err == nil && len(b) > 0 /* #nosec G204 */ {
 			head = strings.TrimSpace(string(b))
@@ -368,12 +621,15 @@ func (dg DefaultGitter) ResetHard(repo, commit string) (err error) {
 	return
 }
 
-func (dg DefaultGitter) FetchTags(repo string) (err error) {
+func (dg DefaultGitter) FetchTags(repo, remote string) (err error) {
 	var b []byte
 	if b, err = dg.Exec("-C", repo, "rev-parse", "--is-shallow-repository"); err == nil {
-		args := []string{"-C", repo, "fetch", "--tags", "--unshallow"}
-		if strings.TrimSpace(string(b)) != "true" {
-			args = args[:len(args)-1]
+		args := []string{"-C", repo, "fetch", "--tags"}
+		if strings.TrimSpace(string(b)) == "true" {
+			args = append(args, "--unshallow")
+		}
+		if remote != "" {
+			args = append(args, remote)
 		}
 		_, err = dg.Exec(args...) /* #nosec G204 */
 	}
@@ -443,16 +699,53 @@ func (dg DefaultGitter) DeleteTag(repo, tag string) (err error) {
 	return
 }
 
-func (dg DefaultGitter) PushTag(repo, tag string) (err error) {
+func remoteOrOrigin(remote string) string {
+	if remote == "" {
+		remote = "origin"
+	}
+	return remote
+}
+
+func (dg DefaultGitter) PushTag(repo, remote, tag, branch string) (err error) {
 	if tag != "" {
-		_, err = dg.Exec("-C", repo, "push", "origin", tag)
+		args := []string{"-C", repo, "push", "--atomic", remoteOrOrigin(remote), tag}
+		if branch != "" {
+			args = append(args, "HEAD:refs/heads/"+branch)
+		}
+		if _, err = dg.Exec(args...); err != nil /* #nosec G204 */ {
+			var execErr *errGitExec
+			if errors.As(err, &execErr) && isPushRejected(execErr.stderr) {
+				err = NewErrPushRejected(remoteOrOrigin(remote), tag, err)
+			}
+		}
 	}
 	return
 }
 
-func (dg DefaultGitter) DeleteRemoteTag(repo, tag string) (err error) {
+func (dg DefaultGitter) UnpushTag(repo, remote, tag, branch, commit string) (err error) {
 	if tag != "" {
-		_, err = dg.Exec("-C", repo, "push", "--delete", "origin", tag)
+		var b []byte
+		if b, err = dg.Exec("-C", repo, "rev-parse", "refs/tags/"+tag, "HEAD"); err == nil /* #nosec G204 */ {
+			if pushed := strings.Fields(string(b)); len(pushed) != 2 {
+				err = NewErrUnexpectedRevParseOutput(1, len(pushed))
+			} else {
+				args := []string{"-C", repo, "push", "--atomic", "--force-with-lease=refs/tags/" + tag + ":" + pushed[0]}
+				refspecs := []string{":refs/tags/" + tag}
+				if branch != "" {
+					args = append(args, "--force-with-lease=refs/heads/"+branch+":"+pushed[1])
+					refspecs = append(refspecs, commit+":refs/heads/"+branch)
+				}
+				args = append(args, remoteOrOrigin(remote))
+				_, err = dg.Exec(append(args, refspecs...)...) /* #nosec G204 */
+			}
+		}
+	}
+	return
+}
+
+func (dg DefaultGitter) FastForward(repo, remote, branch string) (err error) {
+	if _, err = dg.Exec("-C", repo, "fetch", remoteOrOrigin(remote), "refs/heads/"+branch); err == nil /* #nosec G204 */ {
+		_, err = dg.Exec("-C", repo, "merge", "--ff-only", "FETCH_HEAD")
 	}
 	return
 }
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
 )
@@ -193,10 +194,10 @@ func Test_DefaultGitter_GetTags(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if x, err := dg.GetTags("/"); x != nil {
+	if x, err := dg.GetTags("/", ""); x != nil {
 		t.Error(x, err)
 	}
-	alltags, err := dg.GetTags(".")
+	alltags, err := dg.GetTags(".", "")
 	if len(alltags) == 0 {
 		t.Error("no tags")
 	}
@@ -219,7 +220,7 @@ func Test_DefaultGitter_GetTags_IncludesSingleDigitSemverTags(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tags, err := dg.GetTags(repo)
+	tags, err := dg.GetTags(repo, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -243,7 +244,7 @@ func Test_DefaultGitter_GetTags_SortsMixedPrefixSemverDescending(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tags, err := dg.GetTags(repo)
+	tags, err := dg.GetTags(repo, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -265,7 +266,7 @@ func Test_DefaultGitter_GetTags_SortsHugeNumericComponents(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tags, err := dg.GetTags(repo)
+	tags, err := dg.GetTags(repo, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -460,104 +461,81 @@ func Test_DefaultGitter_GetHashesBatch_ReportsEarlierChunkError(t *testing.T) {
 	}
 }
 
-func Test_DefaultGitter_GetClosestTag(t *testing.T) {
-	dg, err := gitsemver.NewDefaultGitter("git", nil)
-	if err != nil {
-		t.Error(err)
-	}
-	if x, err := dg.GetClosestTag("/", ""); x != "" {
-		t.Error(x, err)
-	}
-	tag, err := dg.GetClosestTag(".", "f9a1633a72ca04515d517a830a2e2835a98767f6")
-	if err != nil {
-		t.Error(err)
-	}
-	if tag != "v0.0.2" {
-		t.Error(tag)
-	}
-	tag, err = dg.GetClosestTag(".", "HEAD")
-	if err != nil {
-		t.Error(err)
-	}
-	if tag == "" {
-		t.Error("no closest tag for HEAD")
-	}
-}
-
-func Test_DefaultGitter_GetClosestTag_HEADUsesReachableTag(t *testing.T) {
+func Test_DefaultGitter_GetNearestTag(t *testing.T) {
 	repo := t.TempDir()
 	runGit(t, repo, nil, "init", "-q")
 	runGit(t, repo, nil, "config", "user.email", "test@example.com")
 	runGit(t, repo, nil, "config", "user.name", "Test")
-
-	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
-	runGit(t, repo, nil, "tag", "v1.0.0")
-
-	runGit(t, repo, nil, "checkout", "-q", "-b", "feature")
-	commitAt(t, repo, "a.txt", "a\nb\n", "c2", "2020-01-02T00:00:00Z")
-
-	runGit(t, repo, nil, "checkout", "-q", "-b", "other", "HEAD~1")
-	commitAt(t, repo, "a.txt", "a\nc\n", "c3", "2020-01-03T00:00:00Z")
-	runGit(t, repo, nil, "tag", "v9.0.0")
-
-	runGit(t, repo, nil, "checkout", "-q", "feature")
-
-	dg, err := gitsemver.NewDefaultGitter("git", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	tag, err := dg.GetClosestTag(repo, "HEAD")
-	if err != nil {
-		t.Fatal(err)
-	}
-	if tag != "v1.0.0" {
-		t.Fatalf("expected closest reachable tag v1.0.0, got %q", tag)
-	}
-}
-
-func Test_DefaultGitter_GetClosestTag_IgnoresNonSemverTags(t *testing.T) {
-	repo := t.TempDir()
-	runGit(t, repo, nil, "init", "-q")
-	runGit(t, repo, nil, "config", "user.email", "test@example.com")
-	runGit(t, repo, nil, "config", "user.name", "Test")
-
-	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
-	runGit(t, repo, nil, "tag", "1foo")
+	commitAt(t, repo, "a.txt", "a\n", "first", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.2.0")
+	commitAt(t, repo, "a.txt", "b\n", "second", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.3.0")
+	runGit(t, repo, nil, "tag", "v1.3")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "other")
+	commitAt(t, repo, "b.txt", "c\n", "third", "2020-01-03T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.4.0")
+	runGit(t, repo, nil, "checkout", "-q", "-")
+	commitAt(t, repo, "a.txt", "d\n", "fourth", "2020-01-04T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.5.0")
 
 	dg, err := gitsemver.NewDefaultGitter("git", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	tag, err := dg.GetClosestTag(repo, "HEAD")
+	all, err := dg.GetTagHashes(repo, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if tag != "" {
-		t.Fatalf("expected no closest semver tag, got %q", tag)
+	pick := func(names ...string) (tags []gitsemver.GitTag) {
+		for _, name := range names {
+			for _, gt := range all {
+				if gt.Tag == name {
+					tags = append(tags, gt)
+				}
+			}
+		}
+		return
+	}
+	for _, tc := range []struct {
+		rev      string
+		tags     []string
+		want     string
+		distance int
+	}{
+		{"HEAD", []string{"v1.2.0", "v1.3.0"}, "v1.3.0", 1},
+		{"HEAD", []string{"v1.2.0"}, "v1.2.0", 2},
+		{"HEAD", []string{"v1.4.0"}, "", 0},
+		{"HEAD", nil, "", 0},
+		{"HEAD", []string{"v1.3", "v1.3.0"}, "v1.3", 1},
+		{"HEAD", []string{"v1.3.0", "v1.3"}, "v1.3.0", 1},
+		{"other", []string{"v1.2.0", "v1.5.0", "v1.4.0"}, "v1.4.0", 0},
+	} {
+		tag, distance, err := dg.GetNearestTag(repo, pick(tc.tags...), tc.rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != tc.want || distance != tc.distance {
+			t.Errorf("GetNearestTag(%q, %q) = %q, %d; want %q, %d", tc.rev, tc.tags, tag, distance, tc.want, tc.distance)
+		}
 	}
-}
-
-func Test_DefaultGitter_GetClosestTag_SkipsNonSemverHEADTag(t *testing.T) {
-	repo := t.TempDir()
-	runGit(t, repo, nil, "init", "-q")
-	runGit(t, repo, nil, "config", "user.email", "test@example.com")
-	runGit(t, repo, nil, "config", "user.name", "Test")
-
-	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
-	runGit(t, repo, nil, "tag", "v1.0.0")
-	commitAt(t, repo, "a.txt", "a\nb\n", "c2", "2020-01-02T00:00:00Z")
-	runGit(t, repo, nil, "tag", "1foo")
 
-	dg, err := gitsemver.NewDefaultGitter("git", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	tag, err := dg.GetClosestTag(repo, "HEAD")
-	if err != nil {
-		t.Fatal(err)
+	// both parents of the merge are one commit away, the newer one wins like with "git describe"
+	runGit(t, repo, map[string]string{
+		"GIT_AUTHOR_DATE":    "2020-01-05T00:00:00Z",
+		"GIT_COMMITTER_DATE": "2020-01-05T00:00:00Z",
+	}, "merge", "-q", "--no-ff", "-m", "merge", "other")
+	for _, names := range [][]string{{"v1.4.0", "v1.5.0"}, {"v1.5.0", "v1.4.0"}} {
+		tag, distance, err := dg.GetNearestTag(repo, pick(names...), "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := runGit(t, repo, nil, "describe", "--tags", "--abbrev=0", "--match=v1.4.0", "--match=v1.5.0")
+		if tag != want || distance != 2 {
+			t.Errorf("GetNearestTag(%q) = %q, %d; want %q, 2", names, tag, distance, want)
+		}
 	}
-	if tag != "v1.0.0" {
-		t.Fatalf("expected closest semver tag v1.0.0, got %q", tag)
+	if _, _, err = dg.GetNearestTag(repo, all, "nosuchrev"); err == nil {
+		t.Error("expected error for unknown revision")
 	}
 }
 
@@ -628,6 +606,32 @@ func Test_DefaultGitter_GetBranchFromTag_NormalizesRemoteBranchName(t *testing.T
 	}
 }
 
+func Test_DefaultGitter_GetBranchesContaining(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "branch", "-M", "main")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "feature")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := dg.GetBranchesContaining(repo, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Compare(branches, []string{"feature", "main"}) != 0 {
+		t.Errorf("unexpected branches: %v", branches)
+	}
+	if branches, err = dg.GetBranchesFromTag(repo, "v1.0.0"); slices.Compare(branches, []string{"feature"}) != 0 {
+		t.Errorf("unexpected branches from tag: %v, %v", branches, err)
+	}
+}
+
 func Test_DefaultGitter_GetBuild(t *testing.T) {
 	dg, err := gitsemver.NewDefaultGitter("git", nil)
 	if err != nil {
@@ -726,7 +730,7 @@ func Test_DefaultGitter_FetchTags(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	dg.FetchTags(".")
+	dg.FetchTags(".", "")
 }
 
 func Test_DefaultGitter_CreateDeleteTag(t *testing.T) {
@@ -802,11 +806,11 @@ func Test_DefaultGitter_PushTag(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	err = dg.PushTag(".", "v1.0.0")
+	err = dg.PushTag(".", "", "v1.0.0", "")
 	if err != nil {
 		t.Error(err)
 	}
-	err = dg.PushTag(".", "test-tag")
+	err = dg.PushTag(".", "", "test-tag", "")
 	if err == nil {
 		t.Error("no error")
 	} else {
@@ -817,7 +821,7 @@ func Test_DefaultGitter_PushTag(t *testing.T) {
 	}
 }
 
-func Test_DefaultGitter_DeleteRemoteTag(t *testing.T) {
+func Test_DefaultGitter_PushTagWithBranch(t *testing.T) {
 	base := t.TempDir()
 	origin := filepath.Join(base, "origin.git")
 	work := filepath.Join(base, "work")
@@ -827,20 +831,170 @@ func Test_DefaultGitter_DeleteRemoteTag(t *testing.T) {
 	runGit(t, work, nil, "config", "user.email", "test@example.com")
 	runGit(t, work, nil, "config", "user.name", "Test")
 	commitAt(t, work, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, work, nil, "push", "-q", "origin", "HEAD:refs/heads/main")
+	commitAt(t, work, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
 	runGit(t, work, nil, "tag", "v1.0.0")
-	runGit(t, work, nil, "push", "-q", "origin", "HEAD", "--tags")
 
 	dg, err := gitsemver.NewDefaultGitter("git", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := dg.DeleteRemoteTag(work, "v1.0.0"); err != nil {
+	if err = dg.PushTag(work, "", "v1.0.0", "main"); err != nil {
 		t.Fatal(err)
 	}
+	head := runGit(t, work, nil, "rev-parse", "HEAD")
+	isEqual(t, runGit(t, origin, nil, "rev-parse", "refs/heads/main"), head)
+	isEqual(t, runGit(t, origin, nil, "rev-parse", "v1.0.0"), head)
 
-	remoteTags := runGit(t, work, nil, "ls-remote", "--tags", "origin")
-	if strings.Contains(remoteTags, "refs/tags/v1.0.0") {
-		t.Fatalf("unexpected remote tag after delete: %q", remoteTags)
+	// a rejected branch update must leave the tag unpushed as well
+	runGit(t, work, nil, "reset", "-q", "--hard", "HEAD~1")
+	commitAt(t, work, "a.txt", "c\n", "c3", "2020-01-03T00:00:00Z")
+	runGit(t, work, nil, "tag", "v1.0.1")
+	if err = dg.PushTag(work, "", "v1.0.1", "main"); !errors.Is(err, gitsemver.ErrPushRejected) {
+		t.Fatalf("expected ErrPushRejected, got %v", err)
+	}
+	isEqual(t, runGit(t, origin, nil, "rev-parse", "refs/heads/main"), head)
+	if remoteTags := runGit(t, origin, nil, "tag", "--list"); strings.Contains(remoteTags, "v1.0.1") {
+		t.Fatalf("unexpected remote tag v1.0.1: %q", remoteTags)
+	}
+}
+
+func Test_DefaultGitter_UnpushTag(t *testing.T) {
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+	other := filepath.Join(base, "other")
+
+	runGit(t, base, nil, "init", "--bare", "-q", origin)
+	runGit(t, base, nil, "clone", "-q", origin, work)
+	runGit(t, work, nil, "config", "user.email", "test@example.com")
+	runGit(t, work, nil, "config", "user.name", "Test")
+	commitAt(t, work, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, work, nil, "push", "-q", "origin", "HEAD:refs/heads/main")
+	prev := runGit(t, work, nil, "rev-parse", "HEAD")
+	commitAt(t, work, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, work, nil, "tag", "v1.0.0")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dg.PushTag(work, "", "v1.0.0", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err = dg.UnpushTag(work, "", "v1.0.0", "main", prev); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, runGit(t, origin, nil, "rev-parse", "refs/heads/main"), prev)
+	isEqual(t, runGit(t, origin, nil, "tag", "--list"), "")
+
+	// the branch moved on since the push, so neither ref is touched
+	if err = dg.PushTag(work, "", "v1.0.0", "main"); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, base, nil, "clone", "-q", "-b", "main", origin, other)
+	runGit(t, other, nil, "config", "user.email", "test@example.com")
+	runGit(t, other, nil, "config", "user.name", "Test")
+	commitAt(t, other, "a.txt", "c\n", "c3", "2020-01-03T00:00:00Z")
+	runGit(t, other, nil, "push", "-q", "origin", "main")
+	if err = dg.UnpushTag(work, "", "v1.0.0", "main", prev); err == nil {
+		t.Fatal("no error")
+	}
+	isEqual(t, runGit(t, origin, nil, "rev-parse", "refs/heads/main"), runGit(t, other, nil, "rev-parse", "HEAD"))
+	isEqual(t, runGit(t, origin, nil, "tag", "--list"), "v1.0.0")
+}
+
+// UnpushTag must not push anything if rev-parse doesn't give both the tag
+// and HEAD. Real git never does this, so the test stubs in a fake git binary.
+func Test_DefaultGitter_UnpushTag_UnexpectedOutputCount(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell-based fake git not portable to windows")
+	}
+	dir := t.TempDir()
+	fakeGit := filepath.Join(dir, "git")
+	script := "#!/bin/sh\ncase \"$3\" in push) echo pushed >\"$2/pushed\";; *) printf 'onlyoneline\\n';; esac\nexit 0\n"
+	if err := os.WriteFile(fakeGit, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dg, err := gitsemver.NewDefaultGitter(fakeGit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dg.UnpushTag(dir, "", "v1.0.0", "main", "abc"); !errors.Is(err, gitsemver.ErrUnexpectedRevParseOutput) {
+		t.Fatalf("expected ErrUnexpectedRevParseOutput, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "pushed")); err == nil {
+		t.Fatal("pushed despite the unexpected rev-parse output")
+	}
+}
+
+func Test_DefaultGitter_FastForward(t *testing.T) {
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+	other := filepath.Join(base, "other")
+
+	runGit(t, base, nil, "init", "--bare", "-q", origin)
+	runGit(t, base, nil, "clone", "-q", origin, work)
+	runGit(t, work, nil, "config", "user.email", "test@example.com")
+	runGit(t, work, nil, "config", "user.name", "Test")
+	commitAt(t, work, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, work, nil, "push", "-q", "origin", "HEAD:refs/heads/main")
+	runGit(t, base, nil, "clone", "-q", "-b", "main", origin, other)
+	runGit(t, other, nil, "config", "user.email", "test@example.com")
+	runGit(t, other, nil, "config", "user.name", "Test")
+	commitAt(t, other, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, other, nil, "push", "-q", "origin", "main")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dg.FastForward(work, "", "main"); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, runGit(t, work, nil, "rev-parse", "HEAD"), runGit(t, other, nil, "rev-parse", "HEAD"))
+
+	commitAt(t, work, "a.txt", "c\n", "c3", "2020-01-03T00:00:00Z")
+	commitAt(t, other, "a.txt", "d\n", "c4", "2020-01-04T00:00:00Z")
+	runGit(t, other, nil, "push", "-q", "origin", "main")
+	if err = dg.FastForward(work, "", "main"); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func Test_DefaultGitter_NamedRemote(t *testing.T) {
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.git")
+	work := filepath.Join(base, "work")
+	other := filepath.Join(base, "other")
+
+	runGit(t, base, nil, "init", "--bare", "-q", upstream)
+	runGit(t, base, nil, "init", "-q", work)
+	runGit(t, work, nil, "config", "user.email", "test@example.com")
+	runGit(t, work, nil, "config", "user.name", "Test")
+	runGit(t, work, nil, "remote", "add", "upstream", upstream)
+	commitAt(t, work, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, work, nil, "push", "-q", "upstream", "HEAD")
+	runGit(t, base, nil, "clone", "-q", upstream, other)
+	runGit(t, other, nil, "tag", "v1.0.0")
+	runGit(t, other, nil, "push", "-q", "origin", "v1.0.0")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dg.FetchTags(work, "upstream"); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, nil, "rev-parse", "v1.0.0")
+
+	runGit(t, work, nil, "tag", "v1.0.1")
+	if err = dg.PushTag(work, "upstream", "v1.0.1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if remoteTags := runGit(t, work, nil, "ls-remote", "--tags", "upstream"); !strings.Contains(remoteTags, "refs/tags/v1.0.1") {
+		t.Fatalf("missing remote tag after push: %q", remoteTags)
 	}
 }
 
@@ -1014,3 +1168,341 @@ func Test_DefaultGitter_ExecPreservesErrGitExecInDebugMode(t *testing.T) {
 		t.Fatal("expected debug output")
 	}
 }
+
+func Test_DefaultGitter_GetCommits(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+	commitAt(t, repo, "a.txt", "c\n", "c3", "2020-01-03T00:00:00Z")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := dg.GetCommits(repo, "v1.0.0..HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Fields(runGit(t, repo, nil, "rev-parse", "HEAD", "HEAD~1"))
+	if slices.Compare(commits, want) != 0 {
+		t.Errorf("GetCommits = %q, want %q", commits, want)
+	}
+	if commits, err = dg.GetCommits(repo, "^HEAD~1 HEAD"); err != nil || slices.Compare(commits, want[:1]) != 0 {
+		t.Errorf("GetCommits = %q, %v, want %q", commits, err, want[:1])
+	}
+	if _, err = dg.GetCommits(repo, "nosuchrev"); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
+
+func Test_DefaultGitter_GetCommitMessages(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "feat: first", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "a.txt", "b\n", "fix: second\n\nBREAKING CHANGE: it broke", "2020-01-02T00:00:00Z")
+	commitAt(t, repo, "a.txt", "c\n", "chore: third", "2020-01-03T00:00:00Z")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := dg.GetCommitMessages(repo, "v1.0.0", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"chore: third", "fix: second\n\nBREAKING CHANGE: it broke"}
+	if slices.Compare(messages, want) != 0 {
+		t.Fatalf("unexpected messages: %q", messages)
+	}
+	messages, err = dg.GetCommitMessages(repo, "", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || messages[2] != "feat: first" {
+		t.Fatalf("unexpected messages: %q", messages)
+	}
+	messages, err = dg.GetCommitMessages(repo, "HEAD", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected no messages, got %q", messages)
+	}
+}
+
+func Test_DefaultGitter_GetTags_IncludesPrereleaseTags(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.2.3")
+	runGit(t, repo, nil, "tag", "v1.3.0-rc.1")
+	runGit(t, repo, nil, "tag", "v1.3.0-rc.2")
+	runGit(t, repo, nil, "tag", "v1.3.0")
+	runGit(t, repo, nil, "tag", "v1.3.1+build")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := dg.GetTags(repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Compare(tags, []string{"v1.3.0", "v1.3.0-rc.2", "v1.3.0-rc.1", "v1.2.3"}) != 0 {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+}
+
+func Test_DefaultGitter_GetCommitTime(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "first", "2020-01-02T03:04:05Z")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when, err := dg.GetCommitTime(repo, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !when.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected commit time %v", when)
+	}
+	if _, err = dg.GetCommitTime(repo, "nosuchrev"); err == nil {
+		t.Fatal("expected error for unknown revision")
+	}
+}
+
+func Test_DefaultGitter_GetTags_Prefix(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "first", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	runGit(t, repo, nil, "tag", "tools/cli/v0.1.0")
+	runGit(t, repo, nil, "tag", "tools/cliextra/v9.0.0")
+	runGit(t, repo, nil, "tag", "tools/cli/notsemver")
+	commitAt(t, repo, "a.txt", "b\n", "second", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "tools/cli/v0.2.0")
+	commitAt(t, repo, "a.txt", "c\n", "third", "2020-01-03T00:00:00Z")
+	runGit(t, repo, nil, "tag", "tools/cli/v0.10.0-rc.1")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := dg.GetTags(repo, "tools/cli/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tools/cli/v0.10.0-rc.1", "tools/cli/v0.2.0", "tools/cli/v0.1.0"}
+	if slices.Compare(tags, want) != 0 {
+		t.Fatalf("unexpected tags: %q", tags)
+	}
+	if tags, err = dg.GetTags(repo, ""); err != nil || slices.Compare(tags, []string{"v1.0.0"}) != 0 {
+		t.Fatalf("unexpected tags without prefix: %q %v", tags, err)
+	}
+	if tags, err = dg.GetTags(repo, "tools/[c]li/"); err != nil || len(tags) != 0 {
+		t.Fatalf("unexpected tags with glob characters in the prefix: %q %v", tags, err)
+	}
+}
+
+func Test_DefaultGitter_GetPathHashes(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "other.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v0.1.0")
+	if err := os.MkdirAll(filepath.Join(repo, "tools", "my cli"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, repo, "tools/my cli/main.go", "package main\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "other.txt", "b\n", "c3", "2020-01-03T00:00:00Z")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, tree, err := dg.GetPathHashes(repo, "HEAD", "tools/my cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, strings.TrimSpace(runGit(t, repo, nil, "rev-parse", "HEAD")), commit)
+	isEqual(t, strings.TrimSpace(runGit(t, repo, nil, "rev-parse", "v1.0.0:tools/my cli")), tree)
+
+	hashes, err := dg.GetPathHashesBatch(repo, []string{"v1.0.0", "v0.1.0", "nosuchtag"}, "tools/my cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("unexpected hashes %v", hashes)
+	}
+	isEqual(t, tree, hashes[0].Tree)
+	isEqual(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", hashes[1].Tree)
+
+	if hashes, err = dg.GetPathHashesBatch(repo, nil, "tools/my cli"); err != nil || len(hashes) != 0 {
+		t.Fatalf("unexpected result for no tags: %v %v", hashes, err)
+	}
+	commit, tree, err = dg.GetPathHashes(repo, "v1.0.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, strings.TrimSpace(runGit(t, repo, nil, "rev-parse", "v1.0.0^{commit}")), commit)
+	isEqual(t, strings.TrimSpace(runGit(t, repo, nil, "rev-parse", "v1.0.0^{tree}")), tree)
+}
+
+func Test_DefaultGitter_CountCommits(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	if err := os.MkdirAll(filepath.Join(repo, "tools", "cli"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, repo, "tools/cli/a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	commitAt(t, repo, "other.txt", "a\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "tools/cli/a.txt", "b\n", "c3", "2020-01-03T00:00:00Z")
+	commitAt(t, repo, "other.txt", "b\n", "c4", "2020-01-04T00:00:00Z")
+	commitAt(t, repo, "other.txt", "c\n", "c5", "2020-01-05T00:00:00Z")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		since string
+		path  string
+		want  int
+	}{
+		{since: "", path: "", want: 5},
+		{since: "", path: "tools/cli", want: 2},
+		{since: "v1.0.0", path: "", want: 3},
+		{since: "v1.0.0", path: "tools/cli", want: 1},
+		{since: "HEAD", path: "", want: 0},
+	}
+	for _, tt := range tests {
+		count, err := dg.CountCommits(repo, "HEAD", tt.since, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.want {
+			t.Errorf("CountCommits(%q, %q) = %d, want %d", tt.since, tt.path, count, tt.want)
+		}
+	}
+	if _, err = dg.CountCommits(repo, "HEAD", "nosuchrev", ""); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
+
+func Test_DefaultGitter_GetTagHashes(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	runGit(t, repo, nil, "tag", "-a", "-m", "annotated", "1.0.0")
+	commitAt(t, repo, "a.txt", "b\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "-a", "-m", "annotated", "v1.1.0")
+	runGit(t, repo, nil, "-c", "advice.nestedTag=false", "tag", "-a", "-m", "nested", "v1.2.0", "v1.1.0")
+	runGit(t, repo, nil, "tag", "v2.0.0", "HEAD^{tree}")
+	runGit(t, repo, nil, "tag", "tools/cli/v0.1.0")
+	runGit(t, repo, nil, "tag", "not-semver")
+
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"", "tools/cli/"} {
+		got, err := dg.GetTagHashes(repo, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names, err := dg.GetTags(repo, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = slices.DeleteFunc(names, func(tag string) bool { return tag == "v2.0.0" })
+		want, err := dg.GetHashesBatch(repo, names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("GetTagHashes(%q) = %v\nwant %v", prefix, got, want)
+		}
+	}
+}
+
+// makeManyTagsRepo creates a repository with count tags spread over 100 commits.
+func makeManyTagsRepo(b *testing.B, count int) string {
+	b.Helper()
+	repo := b.TempDir()
+	git := func(stdin string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			b.Fatalf("git %q failed: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("", "init", "-q")
+	var commits []string
+	for i := 0; i < 100; i++ {
+		git("", "-c", "user.email=test@example.com", "-c", "user.name=Test", "commit", "-q", "--allow-empty", "-m", "c"+strconv.Itoa(i))
+		commits = append(commits, git("", "rev-parse", "HEAD"))
+	}
+	var sb strings.Builder
+	for i := 0; i < count; i++ {
+		sb.WriteString("create refs/tags/v1." + strconv.Itoa(i/100) + "." + strconv.Itoa(i%100) + " " + commits[i%100] + "\n")
+	}
+	git(sb.String(), "update-ref", "--stdin")
+	git("", "pack-refs", "--all")
+	return repo
+}
+
+func BenchmarkDefaultGitter_TagScan(b *testing.B) {
+	repo := makeManyTagsRepo(b, 10000)
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("GetTagHashes", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if tags, err := dg.GetTagHashes(repo, ""); err != nil || len(tags) != 10000 {
+				b.Fatal(len(tags), err)
+			}
+		}
+	})
+	b.Run("GetTags+GetHashesBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tags, err := dg.GetTags(repo, "")
+			if err == nil {
+				var hashes []gitsemver.GitTag
+				if hashes, err = dg.GetHashesBatch(repo, tags); err == nil && len(hashes) != 10000 {
+					b.Fatal(len(hashes))
+				}
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
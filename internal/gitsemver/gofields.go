@@ -0,0 +1,53 @@
+package gitsemver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GoFields selects the optional constants written by VersionInfo.GoPackageFields.
+type GoFields int
+
+const (
+	GoFieldCommit     GoFields = 1 << iota // PkgCommit, the HEAD commit hash
+	GoFieldTree                            // PkgTree, the HEAD tree hash
+	GoFieldBranch                          // PkgBranch, the git branch
+	GoFieldBuild                           // PkgBuild, the build number
+	GoFieldCommitTime                      // PkgCommitTime, the HEAD committer time in RFC 3339 format, UTC
+	GoFieldDirty                           // PkgDirty, true if there were uncommitted changes
+	GoFieldsAll       = GoFieldCommit | GoFieldTree | GoFieldBranch | GoFieldBuild | GoFieldCommitTime | GoFieldDirty
+)
+
+var goFieldNames = []struct {
+	name  string
+	field GoFields
+}{
+	{"commit", GoFieldCommit},
+	{"tree", GoFieldTree},
+	{"branch", GoFieldBranch},
+	{"build", GoFieldBuild},
+	{"committime", GoFieldCommitTime},
+	{"dirty", GoFieldDirty},
+	{"all", GoFieldsAll},
+}
+
+// ParseGoFields parses a comma-separated list of field names
+// (commit, tree, branch, build, committime, dirty or all).
+// An empty string selects no fields.
+func ParseGoFields(names string) (fields GoFields, err error) {
+	for name := range strings.SplitSeq(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			found := false
+			for _, fn := range goFieldNames {
+				if fn.name == name {
+					fields |= fn.field
+					found = true
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("unknown Go package field %q", name)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package gitsemver_test
+
+import (
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_ParseGoFields(t *testing.T) {
+	fields, err := gitsemver.ParseGoFields("")
+	isEqual(t, nil, err)
+	isEqual(t, gitsemver.GoFields(0), fields)
+
+	fields, err = gitsemver.ParseGoFields("commit, dirty")
+	isEqual(t, nil, err)
+	isEqual(t, gitsemver.GoFieldCommit|gitsemver.GoFieldDirty, fields)
+
+	fields, err = gitsemver.ParseGoFields("all,branch")
+	isEqual(t, nil, err)
+	isEqual(t, gitsemver.GoFieldsAll, fields)
+
+	fields, err = gitsemver.ParseGoFields("commit,bogus")
+	if err == nil {
+		t.Error("no error")
+	}
+	isEqual(t, gitsemver.GoFields(0), fields)
+}
@@ -0,0 +1,417 @@
+package gitsemver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitInternal is the Git executable name that selects the InternalGitter in New.
+const GitInternal = "internal"
+
+// InternalGitter is a Gitter that reads refs and objects directly from the
+// .git directory instead of running git. Methods it doesn't implement,
+// including all that modify the repository, are delegated to Gitter.
+// Repositories using features it doesn't support, like reftables or
+// split indexes, are delegated to Gitter as well.
+type InternalGitter struct {
+	Gitter             // used for the methods not implemented here
+	DebugOut io.Writer // if not nil, write debug output here
+	mu       sync.Mutex
+	repos    map[string]*gitRepo
+}
+
+// NewInternalGitter returns an InternalGitter that delegates to a DefaultGitter using gitBin.
+// It succeeds even if gitBin can't be found, in which case the delegated methods fail.
+func NewInternalGitter(gitBin string, debugOut io.Writer) (gitter Gitter, err error) {
+	if path, e := exec.LookPath(gitBin); e == nil {
+		gitBin = path
+	}
+	gitter = &InternalGitter{
+		Gitter:   DefaultGitter{Git: gitBin, DebugOut: debugOut},
+		DebugOut: debugOut,
+		repos:    map[string]*gitRepo{},
+	}
+	return
+}
+
+func (ig *InternalGitter) debug(f string, args ...any) {
+	if ig.DebugOut != nil {
+		_, _ = fmt.Fprintf(ig.DebugOut, "internal "+f+"\n", args...)
+		MaybeSync(ig.DebugOut)
+	}
+}
+
+// open returns the gitRepo for repo, reusing an already opened one.
+// Returns errUnsupportedRepo if the methods should be delegated.
+func (ig *InternalGitter) open(repo string) (r *gitRepo, err error) {
+	if r = ig.repos[repo]; r == nil {
+		if r, err = openGitRepo(repo); err == nil {
+			ig.repos[repo] = r
+		} else if errors.Is(err, errUnsupportedRepo) {
+			ig.debug("%s: %v, using git", repo, err)
+		}
+	}
+	return
+}
+
+// Close closes the files kept open for the repositories read so far.
+func (ig *InternalGitter) Close() error {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+	for repo, r := range ig.repos {
+		r.close()
+		delete(ig.repos, repo)
+	}
+	return nil
+}
+
+// withRepo calls fn with the gitRepo for repo and returns true, or returns false
+// if the repository format or a revision isn't supported and the call should be delegated.
+func (ig *InternalGitter) withRepo(repo string, err *error, fn func(r *gitRepo) error) bool {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+	r, e := ig.open(repo)
+	if errors.Is(e, errUnsupportedRepo) {
+		return false
+	}
+	if e == nil {
+		if e = fn(r); errors.Is(e, errUnsupportedRev) || errors.Is(e, errUnsupportedRepo) {
+			ig.debug("%s: %v, using git", repo, e)
+			return false
+		}
+	}
+	*err = e
+	return true
+}
+
+// GetTags returns all tags that are the prefix followed by a semver version,
+// sorted by version descending. The latest tag is the first in the list.
+func (ig *InternalGitter) GetTags(repo, prefix string) (tags []string, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var refs map[string]string
+		if refs, err = r.listRefs("refs/tags/"); err == nil {
+			for name := range refs {
+				if tag := strings.TrimPrefix(name, "refs/tags/"); isPrefixedSemverTag(prefix, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(tags)))
+			sort.SliceStable(tags, func(i, j int) bool {
+				return semverTagGreater(tags[i][len(prefix):], tags[j][len(prefix):])
+			})
+			ig.debug("tags %q: %d", prefix, len(tags))
+		}
+		return
+	}) {
+		return ig.Gitter.GetTags(repo, prefix)
+	}
+	return
+}
+
+// GetTagHashes returns the tags that are the prefix followed by a semver version with their
+// commit and root tree hashes, sorted by version descending.
+func (ig *InternalGitter) GetTagHashes(repo, prefix string) (tags []GitTag, err error) {
+	var names []string
+	if names, err = ig.GetTags(repo, prefix); err == nil {
+		tags, err = ig.GetHashesBatch(repo, names)
+	}
+	return
+}
+
+// hashes returns the commit for rev and the object name of path in its tree,
+// and whether rev is an annotated tag. Both are empty if rev doesn't exist,
+// and tree is the empty tree if path doesn't.
+func (r *gitRepo) hashes(rev, path string) (commit, tree string, annotated bool, err error) {
+	var object string
+	if object, err = r.resolve(rev); err == nil && object != "" {
+		if commit, err = r.peel(object); err == nil {
+			annotated = commit != object
+			var c *gitCommit
+			if c, err = r.commit(commit); err == nil {
+				if _, tree, err = r.treeEntry(c.tree, path); err == nil && tree == "" {
+					tree = emptyTreeHash(commit)
+				}
+			}
+		}
+	}
+	if err != nil {
+		commit, tree = "", ""
+	}
+	return
+}
+
+// GetHashes returns the target commit and tree hashes for the given tag.
+func (ig *InternalGitter) GetHashes(repo, tag string) (commit, tree string, err error) {
+	return ig.GetPathHashes(repo, tag, "")
+}
+
+// GetHashesBatch returns commit/tree hashes for many tags. Tags that don't exist are left out.
+func (ig *InternalGitter) GetHashesBatch(repo string, tags []string) (hashes []GitTag, err error) {
+	return ig.GetPathHashesBatch(repo, tags, "")
+}
+
+// GetPathHashes returns the target commit hash for the given tag and the hash of the path in it.
+// If the path doesn't exist in the tag, the empty tree hash is returned. An empty path means the root tree.
+func (ig *InternalGitter) GetPathHashes(repo, tag, path string) (commit, tree string, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		if commit, tree, _, err = r.hashes(tag, path); err == nil && commit == "" {
+			_, err = r.mustResolve(tag)
+		}
+		return
+	}) {
+		return ig.Gitter.GetPathHashes(repo, tag, path)
+	}
+	return
+}
+
+// GetPathHashesBatch returns the commit hashes and path hashes for many tags.
+// Tags that don't exist are left out.
+func (ig *InternalGitter) GetPathHashesBatch(repo string, tags []string, path string) (hashes []GitTag, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		hashes = make([]GitTag, 0, len(tags))
+		for _, tag := range tags {
+			var commit, tree string
+			var annotated bool
+			if commit, tree, annotated, err = r.hashes(tag, path); err != nil {
+				hashes = nil
+				return
+			}
+			if commit != "" {
+				hashes = append(hashes, GitTag{Tag: tag, Commit: commit, Tree: tree, Annotated: annotated})
+			}
+		}
+		return
+	}) {
+		return ig.Gitter.GetPathHashesBatch(repo, tags, path)
+	}
+	return
+}
+
+// commitGraph returns the history reachable from rev, with the commits
+// in the reverse order of a depth-first postorder walk.
+func (r *gitRepo) commitGraph(rev string) (g *commitGraph, err error) {
+	var start string
+	if start, err = r.mustResolve(rev); err == nil {
+		if start, err = r.peel(start); err == nil {
+			g, err = walkCommitGraph(start, r.commit)
+		}
+	}
+	return
+}
+
+// GetNearestTag returns the tag among tags that is nearest to the given commit by ancestry,
+// and the number of commits reachable from the commit but not from the tag.
+func (ig *InternalGitter) GetNearestTag(repo string, tags []GitTag, commit string) (tag string, distance int, err error) {
+	if len(tags) > 0 {
+		if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+			var g *commitGraph
+			if g, err = r.commitGraph(commit); err == nil {
+				tag, distance = g.nearestTag(g.head(), tags)
+			}
+			return
+		}) {
+			return ig.Gitter.GetNearestTag(repo, tags, commit)
+		}
+	}
+	return
+}
+
+// commitGraph returns the history reachable from rev.
+func (ig *InternalGitter) commitGraph(repo, rev string) (g *commitGraph, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		g, err = r.commitGraph(rev)
+		return
+	}) {
+		if grapher, ok := ig.Gitter.(commitGrapher); ok {
+			return grapher.commitGraph(repo, rev)
+		}
+		err = errors.ErrUnsupported
+	}
+	return
+}
+
+// GetBranch returns the current branch, or an empty string for a detached HEAD.
+func (ig *InternalGitter) GetBranch(repo string) (branch string, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var target string
+		if target, err = r.symbolicRef("HEAD"); err == nil {
+			branch = strings.TrimPrefix(target, "refs/heads/")
+		}
+		return
+	}) {
+		return ig.Gitter.GetBranch(repo)
+	}
+	return
+}
+
+// walk calls fn for every commit reachable from rev but not from any of the
+// commits in exclude, and returns the visited commits. Parents are only
+// followed if fn returns true for them, or all of them if fn is nil.
+func (r *gitRepo) walk(rev string, exclude map[string]bool, fn func(hash string, c *gitCommit) (parents []string, err error)) (seen map[string]bool, err error) {
+	seen = map[string]bool{}
+	var start string
+	if start, err = r.mustResolve(rev); err == nil {
+		if start, err = r.peel(start); err == nil {
+			for stack := []string{start}; len(stack) > 0 && err == nil; {
+				hash := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if !seen[hash] && !exclude[hash] {
+					seen[hash] = true
+					var c *gitCommit
+					if c, err = r.commit(hash); err == nil {
+						parents := c.parents
+						if fn != nil {
+							parents, err = fn(hash, c)
+						}
+						stack = append(stack, parents...)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// reachable returns the commits reachable from rev, or none if rev is empty.
+func (r *gitRepo) reachable(rev string) (seen map[string]bool, err error) {
+	if rev != "" {
+		seen, err = r.walk(rev, nil, nil)
+	}
+	return
+}
+
+// GetBuild returns the number of commits reachable from HEAD as a string, or an empty string.
+func (ig *InternalGitter) GetBuild(repo string) (buildnum string, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var seen map[string]bool
+		if seen, err = r.reachable("HEAD"); err == nil && len(seen) > 0 {
+			buildnum = strconv.Itoa(len(seen))
+		}
+		return
+	}) {
+		return ig.Gitter.GetBuild(repo)
+	}
+	return
+}
+
+// CountCommits returns the number of commits reachable from rev but not from since,
+// only counting commits that touch path. Like "git rev-list", merges that have the
+// same path contents as one of their parents only follow that parent and aren't counted.
+func (ig *InternalGitter) CountCommits(repo, rev, since, path string) (count int, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var exclude map[string]bool
+		if exclude, err = r.reachable(since); err == nil {
+			pathHash := func(c *gitCommit) (hash string, err error) {
+				_, hash, err = r.treeEntry(c.tree, path)
+				return
+			}
+			_, err = r.walk(rev, exclude, func(hash string, c *gitCommit) (parents []string, err error) {
+				parents = c.parents
+				if path == "" {
+					count++
+					return
+				}
+				var own string
+				if own, err = pathHash(c); err == nil {
+					changed := len(c.parents) == 0 && own != ""
+					for i, parent := range c.parents {
+						var pc *gitCommit
+						if pc, err = r.commit(parent); err == nil {
+							var theirs string
+							if theirs, err = pathHash(pc); err == nil {
+								if theirs == own {
+									return c.parents[i : i+1], nil
+								}
+								changed = true
+							}
+						}
+						if err != nil {
+							return
+						}
+					}
+					if changed {
+						count++
+					}
+				}
+				return
+			})
+		}
+		return
+	}) {
+		return ig.Gitter.CountCommits(repo, rev, since, path)
+	}
+	return
+}
+
+// GetCommitMessages returns the full commit messages of the commits reachable
+// from to but not from from, newest committer time first.
+func (ig *InternalGitter) GetCommitMessages(repo, from, to string) (messages []string, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var exclude, seen map[string]bool
+		if exclude, err = r.reachable(from); err == nil {
+			if seen, err = r.walk(to, exclude, nil); err == nil {
+				var commits []*gitCommit
+				for hash := range seen {
+					commits = append(commits, r.commits[hash])
+				}
+				messages = commitMessages(commits)
+			}
+		}
+		return
+	}) {
+		return ig.Gitter.GetCommitMessages(repo, from, to)
+	}
+	return
+}
+
+// GetCommitTime returns the committer time of the given revision.
+func (ig *InternalGitter) GetCommitTime(repo, rev string) (when time.Time, err error) {
+	if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		var hash string
+		if hash, err = r.mustResolve(rev); err == nil {
+			if hash, err = r.peel(hash); err == nil {
+				var c *gitCommit
+				if c, err = r.commit(hash); err == nil {
+					when = c.when
+				}
+			}
+		}
+		return
+	}) {
+		return ig.Gitter.GetCommitTime(repo, rev)
+	}
+	return
+}
+
+// GetHead returns the current HEAD commit hash if skip is false.
+func (ig *InternalGitter) GetHead(repo string, skip bool) (head string, err error) {
+	if !skip {
+		if !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+			head, err = r.mustResolve("HEAD")
+			return
+		}) {
+			return ig.Gitter.GetHead(repo, skip)
+		}
+	}
+	return
+}
+
+// CleanStatus returns true if there are no uncommitted changes in the repo. Untracked
+// files are only checked by the delegated Gitter, which is used if includeUntracked is true,
+// or if a tracked file's stat data don't show it to be unchanged.
+func (ig *InternalGitter) CleanStatus(repo string, includeUntracked bool) (yes bool, err error) {
+	if includeUntracked || !ig.withRepo(repo, &err, func(r *gitRepo) (err error) {
+		yes, err = r.cleanStatus(repo)
+		return
+	}) {
+		return ig.Gitter.CleanStatus(repo, includeUntracked)
+	}
+	return
+}
@@ -0,0 +1,335 @@
+package gitsemver_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func newInternalGitter(t *testing.T) *gitsemver.InternalGitter {
+	t.Helper()
+	g, err := gitsemver.NewInternalGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ig := g.(*gitsemver.InternalGitter)
+	t.Cleanup(func() { _ = ig.Close() })
+	return ig
+}
+
+// makeHistoryRepo creates a repository with branches, a merge,
+// lightweight and annotated tags and files in subdirectories.
+func makeHistoryRepo(t *testing.T, initArgs ...string) string {
+	t.Helper()
+	repo := t.TempDir()
+	runGit(t, repo, nil, append([]string{"init", "-q", "-b", "main"}, initArgs...)...)
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	if err := os.MkdirAll(filepath.Join(repo, "tools", "cli"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	commitAt(t, repo, "a.txt", "a\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "tools/cli/x.txt", "x\n", "feat: cli", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "tag", "tools/cli/v0.1.0")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "side")
+	commitAt(t, repo, "side.txt", "s\n", "fix: side", "2020-01-03T00:00:00Z")
+	runGit(t, repo, nil, "tag", "-a", "-m", "annotated", "v1.0.1")
+	commitAt(t, repo, "tools/cli/x.txt", "y\n", "fix: cli on side", "2020-01-04T00:00:00Z")
+	runGit(t, repo, nil, "checkout", "-q", "main")
+	commitAt(t, repo, "a.txt", "b\n", "feat: main\n\nbody", "2020-01-05T00:00:00Z")
+	runGit(t, repo, nil, "tag", "-a", "-m", "annotated", "v1.1.0")
+	runGit(t, repo, map[string]string{
+		"GIT_AUTHOR_DATE":    "2020-01-06T00:00:00Z",
+		"GIT_COMMITTER_DATE": "2020-01-06T00:00:00Z",
+	}, "merge", "-q", "--no-ff", "-m", "merge side", "side")
+	commitAt(t, repo, "a.txt", "c\n", "c7", "2020-01-07T00:00:00Z")
+	runGit(t, repo, nil, "tag", "not-semver")
+	return repo
+}
+
+// compareGitters checks that the InternalGitter returns what the DefaultGitter does.
+func compareGitters(t *testing.T, repo string) {
+	t.Helper()
+	dg, err := gitsemver.NewDefaultGitter("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ig := newInternalGitter(t)
+
+	for _, prefix := range []string{"", "v", "tools/cli/"} {
+		want, err1 := dg.GetTags(repo, prefix)
+		got, err2 := ig.GetTags(repo, prefix)
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("GetTags(%q) = %q, %v; want %q, %v", prefix, got, err2, want, err1)
+		}
+		wantHashes, err1 := dg.GetTagHashes(repo, prefix)
+		gotHashes, err2 := ig.GetTagHashes(repo, prefix)
+		if err1 != nil || err2 != nil || !slices.Equal(gotHashes, wantHashes) {
+			t.Errorf("GetTagHashes(%q) = %v, %v; want %v, %v", prefix, gotHashes, err2, wantHashes, err1)
+		}
+	}
+
+	tags := []string{"v1.0.0", "v1.0.1", "v1.1.0", "tools/cli/v0.1.0"}
+	for _, path := range []string{"", "tools/cli", "tools/cli/x.txt", "missing"} {
+		want, err1 := dg.GetPathHashesBatch(repo, tags, path)
+		got, err2 := ig.GetPathHashesBatch(repo, tags, path)
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("GetPathHashesBatch(%q) = %v, %v; want %v, %v", path, got, err2, want, err1)
+		}
+		for _, since := range []string{"", "v1.0.0", "v1.0.1", "HEAD"} {
+			want, err1 := dg.CountCommits(repo, "HEAD", since, path)
+			got, err2 := ig.CountCommits(repo, "HEAD", since, path)
+			if err1 != nil || err2 != nil || got != want {
+				t.Errorf("CountCommits(%q, %q) = %d, %v; want %d, %v", since, path, got, err2, want, err1)
+			}
+		}
+	}
+
+	wantCommit, wantTree, err1 := dg.GetHashes(repo, "v1.1.0")
+	gotCommit, gotTree, err2 := ig.GetHashes(repo, "v1.1.0")
+	if err1 != nil || err2 != nil || gotCommit != wantCommit || gotTree != wantTree {
+		t.Errorf("GetHashes() = %q, %q, %v; want %q, %q, %v", gotCommit, gotTree, err2, wantCommit, wantTree, err1)
+	}
+	if _, _, err = ig.GetHashes(repo, "v9.9.9"); err == nil {
+		t.Error("expected error for missing tag")
+	}
+	if hashes, err := ig.GetHashesBatch(repo, []string{"v9.9.9", "v1.0.0"}); err != nil || len(hashes) != 1 || hashes[0].Tag != "v1.0.0" {
+		t.Errorf("GetHashesBatch() = %v, %v; want only v1.0.0", hashes, err)
+	}
+
+	nearest, err := dg.GetTagHashes(repo, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nearest = nearest[1:]
+	wantTag, wantDistance, err1 := dg.GetNearestTag(repo, nearest, "HEAD")
+	gotTag, gotDistance, err2 := ig.GetNearestTag(repo, nearest, "HEAD")
+	if err1 != nil || err2 != nil || gotTag != wantTag || gotDistance != wantDistance {
+		t.Errorf("GetNearestTag() = %q, %d, %v; want %q, %d, %v", gotTag, gotDistance, err2, wantTag, wantDistance, err1)
+	}
+
+	for _, rng := range [][2]string{{"", "HEAD"}, {"v1.0.0", "HEAD"}, {"v1.1.0", "HEAD~1"}} {
+		want, err1 := dg.GetCommitMessages(repo, rng[0], rng[1])
+		got, err2 := ig.GetCommitMessages(repo, rng[0], rng[1])
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("GetCommitMessages(%q, %q) = %q, %v; want %q, %v", rng[0], rng[1], got, err2, want, err1)
+		}
+	}
+
+	wantBranch, err1 := dg.GetBranch(repo)
+	gotBranch, err2 := ig.GetBranch(repo)
+	if err1 != nil || err2 != nil || gotBranch != wantBranch {
+		t.Errorf("GetBranch() = %q, %v; want %q, %v", gotBranch, err2, wantBranch, err1)
+	}
+	wantBuild, err1 := dg.GetBuild(repo)
+	gotBuild, err2 := ig.GetBuild(repo)
+	if err1 != nil || err2 != nil || gotBuild != wantBuild {
+		t.Errorf("GetBuild() = %q, %v; want %q, %v", gotBuild, err2, wantBuild, err1)
+	}
+	wantHead, err1 := dg.GetHead(repo, false)
+	gotHead, err2 := ig.GetHead(repo, false)
+	if err1 != nil || err2 != nil || gotHead != wantHead {
+		t.Errorf("GetHead() = %q, %v; want %q, %v", gotHead, err2, wantHead, err1)
+	}
+	wantTime, err1 := dg.GetCommitTime(repo, "v1.0.1")
+	gotTime, err2 := ig.GetCommitTime(repo, "v1.0.1")
+	if err1 != nil || err2 != nil || !gotTime.Equal(wantTime) {
+		t.Errorf("GetCommitTime() = %v, %v; want %v, %v", gotTime, err2, wantTime, err1)
+	}
+	wantClean, err1 := dg.CleanStatus(repo, false)
+	gotClean, err2 := ig.CleanStatus(repo, false)
+	if err1 != nil || err2 != nil || gotClean != wantClean {
+		t.Errorf("CleanStatus() = %v, %v; want %v, %v", gotClean, err2, wantClean, err1)
+	}
+}
+
+func Test_InternalGitter_LooseObjects(t *testing.T) {
+	compareGitters(t, makeHistoryRepo(t))
+}
+
+func Test_InternalGitter_PackedObjects(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	runGit(t, repo, nil, "gc", "-q", "--aggressive")
+	compareGitters(t, repo)
+	if _, err := os.Stat(filepath.Join(repo, ".git", "packed-refs")); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_InternalGitter_SHA256(t *testing.T) {
+	repo := makeHistoryRepo(t, "--object-format=sha256")
+	compareGitters(t, repo)
+	runGit(t, repo, nil, "gc", "-q")
+	compareGitters(t, repo)
+}
+
+func Test_InternalGitter_Worktree(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	runGit(t, repo, nil, "gc", "-q")
+	wt := filepath.Join(t.TempDir(), "wt")
+	runGit(t, repo, nil, "worktree", "add", "-q", "-b", "wtbranch", wt, "v1.0.1")
+	compareGitters(t, wt)
+	ig := newInternalGitter(t)
+	if branch, err := ig.GetBranch(wt); err != nil || branch != "wtbranch" {
+		t.Errorf("GetBranch() = %q, %v", branch, err)
+	}
+}
+
+func Test_InternalGitter_DetachedHead(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	runGit(t, repo, nil, "checkout", "-q", "v1.0.1")
+	compareGitters(t, repo)
+}
+
+func Test_InternalGitter_Shallow(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	for _, depth := range []string{"1", "3"} {
+		clone := filepath.Join(t.TempDir(), "clone")
+		runGit(t, repo, nil, "clone", "-q", "--depth", depth, "file://"+repo, clone)
+		dg, err := gitsemver.NewDefaultGitter("git", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ig := newInternalGitter(t)
+
+		wantBuild, err1 := dg.GetBuild(clone)
+		gotBuild, err2 := ig.GetBuild(clone)
+		if err1 != nil || err2 != nil || gotBuild != wantBuild {
+			t.Errorf("depth %s: GetBuild() = %q, %v; want %q, %v", depth, gotBuild, err2, wantBuild, err1)
+		}
+		for _, path := range []string{"", "tools/cli"} {
+			want, err1 := dg.CountCommits(clone, "HEAD", "", path)
+			got, err2 := ig.CountCommits(clone, "HEAD", "", path)
+			if err1 != nil || err2 != nil || got != want {
+				t.Errorf("depth %s: CountCommits(%q) = %d, %v; want %d, %v", depth, path, got, err2, want, err1)
+			}
+		}
+		want, err1 := dg.GetCommitMessages(clone, "", "HEAD")
+		got, err2 := ig.GetCommitMessages(clone, "", "HEAD")
+		if err1 != nil || err2 != nil || !slices.Equal(got, want) {
+			t.Errorf("depth %s: GetCommitMessages() = %q, %v; want %q, %v", depth, got, err2, want, err1)
+		}
+
+		vs := &gitsemver.GitSemVer{Git: ig, Env: MockEnvironment{}}
+		vi, err := vs.GetVersion(clone)
+		if err != nil {
+			t.Fatalf("depth %s: %v", depth, err)
+		}
+		vs = &gitsemver.GitSemVer{Git: dg, Env: MockEnvironment{}}
+		wantVI, err := vs.GetVersion(clone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isEqual(t, vi.Version(), wantVI.Version())
+	}
+}
+
+func Test_InternalGitter_CleanStatus(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	ig := newInternalGitter(t)
+	check := func(what string, want bool) {
+		t.Helper()
+		if clean, err := ig.CleanStatus(repo, false); err != nil || clean != want {
+			t.Errorf("%s: CleanStatus() = %v, %v; want %v", what, clean, err, want)
+		}
+	}
+	write := func(fileName, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, fileName), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("committed", true)
+	write("untracked.txt", "u\n")
+	check("untracked", true)
+	if clean, err := ig.CleanStatus(repo, true); err != nil || clean {
+		t.Errorf("CleanStatus(untracked) = %v, %v", clean, err)
+	}
+	write("a.txt", "changed\n")
+	check("modified", false)
+	write("a.txt", "c\n")
+	check("rewritten with same content", true)
+	write("tools/cli/x.txt", "staged\n")
+	runGit(t, repo, nil, "add", "tools/cli/x.txt")
+	check("staged", false)
+	runGit(t, repo, nil, "reset", "-q", "--hard")
+	check("reset", true)
+	if err := os.Chmod(filepath.Join(repo, "a.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	check("mode changed", false)
+	runGit(t, repo, nil, "reset", "-q", "--hard")
+	if err := os.Remove(filepath.Join(repo, "side.txt")); err != nil {
+		t.Fatal(err)
+	}
+	check("deleted", false)
+	runGit(t, repo, nil, "reset", "-q", "--hard")
+	runGit(t, repo, nil, "update-index", "--index-version", "4")
+	check("index v4", true)
+	runGit(t, repo, nil, "add", "-N", "untracked.txt")
+	check("intent to add", false)
+	runGit(t, repo, nil, "reset", "-q", "--hard")
+
+	// checked out with CRLF line endings, which git's autocrlf filter
+	// removes again when comparing the touched file
+	fileName := filepath.Join(repo, "a.txt")
+	runGit(t, repo, nil, "config", "core.autocrlf", "true")
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, nil, "checkout", "--", "a.txt")
+	earlier := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(fileName, earlier, earlier); err != nil {
+		t.Fatal(err)
+	}
+	check("autocrlf", true)
+	runGit(t, repo, nil, "config", "--unset", "core.autocrlf")
+	if err := os.Remove(fileName); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, nil, "reset", "-q", "--hard")
+
+	// a change after the index was written that keeps the size and mtime
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, nil, "update-index", "--refresh")
+	write("a.txt", "x\n")
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	check("racy", false)
+}
+
+func Test_InternalGitter_Unsupported(t *testing.T) {
+	repo := makeHistoryRepo(t)
+	runGit(t, repo, nil, "update-index", "--split-index")
+	compareGitters(t, repo)
+
+	ig := newInternalGitter(t)
+	if commit, _, err := ig.GetHashes(repo, "HEAD~1"); err != nil || commit == "" {
+		t.Errorf("GetHashes(HEAD~1) = %q, %v", commit, err)
+	}
+	if _, err := ig.CountCommits(repo, "HEAD", "nosuchrev", ""); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+	if _, err := ig.GetTags(t.TempDir(), ""); err == nil {
+		t.Error("expected error for directory without repository")
+	}
+}
+
+func Test_New_Internal(t *testing.T) {
+	vs, err := gitsemver.New(gitsemver.GitInternal, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vs.Git.(*gitsemver.InternalGitter); !ok {
+		t.Errorf("%T", vs.Git)
+	}
+}
@@ -2,7 +2,9 @@ package gitsemver_test
 
 import (
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
 )
@@ -20,13 +22,13 @@ func (me MockEnvironment) LookupEnv(key string) (val string, ok bool) {
 }
 
 var mockHistory = []gitsemver.GitTag{
-	{"HEAD", "commit-7", "tree-7"},
-	{"v6.0.0", "commit-6", "tree-6"},
-	{"", "commit-5", "tree-5"},
-	{"v4.0.0", "commit-4", "tree-4"},
-	{"", "commit-3", "tree-3"},
-	{"v2.0.0", "commit-2", "tree-2"},
-	{"", "commit-1", "tree-1"},
+	{Tag: "HEAD", Commit: "commit-7", Tree: "tree-7"},
+	{Tag: "v6.0.0", Commit: "commit-6", Tree: "tree-6"},
+	{Tag: "", Commit: "commit-5", Tree: "tree-5"},
+	{Tag: "v4.0.0", Commit: "commit-4", Tree: "tree-4"},
+	{Tag: "", Commit: "commit-3", Tree: "tree-3"},
+	{Tag: "v2.0.0", Commit: "commit-2", Tree: "tree-2"},
+	{Tag: "", Commit: "commit-1", Tree: "tree-1"},
 }
 
 type MockGitter struct {
@@ -35,6 +37,11 @@ type MockGitter struct {
 	TopTag        string
 	dirty         bool
 	closestTagErr error
+	messages      []string
+	messagesFrom  string
+	commitTime    time.Time
+	countSince    string
+	countPath     string
 }
 
 func (mg *MockGitter) Exec(args ...string) (output []byte, err error) {
@@ -48,10 +55,10 @@ func (mg *MockGitter) CheckGitRepo(dir string) (repo string, err error) {
 	return dir, os.ErrNotExist
 }
 
-func (mg *MockGitter) GetTags(repo string) (tags []string, err error) {
+func (mg *MockGitter) GetTags(repo, prefix string) (tags []string, err error) {
 	if repo == "." {
 		for _, h := range mockHistory {
-			if h.Tag != "" && h.Tag != "HEAD" {
+			if h.Tag != "" && h.Tag != "HEAD" && strings.HasPrefix(h.Tag, prefix) {
 				tags = append(tags, h.Tag)
 			}
 		}
@@ -59,6 +66,14 @@ func (mg *MockGitter) GetTags(repo string) (tags []string, err error) {
 	return
 }
 
+func (mg *MockGitter) GetTagHashes(repo, prefix string) (tags []gitsemver.GitTag, err error) {
+	var names []string
+	if names, err = mg.GetTags(repo, prefix); err == nil {
+		tags, err = mg.GetHashesBatch(repo, names)
+	}
+	return
+}
+
 func (mg *MockGitter) GetCurrentTreeHash(repo string) (string, error) {
 	if repo == "." {
 		if mg.treehash == "" {
@@ -101,24 +116,38 @@ func (mg *MockGitter) GetHashesBatch(repo string, tags []string) (hashes []gitse
 	return
 }
 
-func (mg *MockGitter) GetClosestTag(repo, from string) (tag string, err error) {
+func (mg *MockGitter) GetPathHashes(repo, tag, path string) (commit, tree string, err error) {
+	if commit, tree, err = mg.GetHashes(repo, tag); path != "" && commit != "" {
+		tree = path + "@" + tree
+	}
+	return
+}
+
+func (mg *MockGitter) GetPathHashesBatch(repo string, tags []string, path string) (hashes []gitsemver.GitTag, err error) {
+	for _, tag := range tags {
+		commit, tree, _ := mg.GetPathHashes(repo, tag, path)
+		if commit != "" && tree != "" {
+			hashes = append(hashes, gitsemver.GitTag{Tag: tag, Commit: commit, Tree: tree})
+		}
+	}
+	return
+}
+
+func (mg *MockGitter) GetNearestTag(repo string, tags []gitsemver.GitTag, from string) (tag string, distance int, err error) {
 	if mg.closestTagErr != nil {
-		return "", mg.closestTagErr
+		return "", 0, mg.closestTagErr
 	}
-	if repo == "." {
-		if from == "HEAD" {
-			from = mg.treehash
-			if from == "" {
-				from = mockHistory[0].Tree
-			}
+	if repo == "." && from == "HEAD" {
+		from = mg.treehash
+		if from == "" {
+			from = mockHistory[0].Tree
 		}
 		for i := range mockHistory {
 			if mockHistory[i].Tree == from {
-				for i < len(mockHistory) {
-					if mockHistory[i].Tag != "" && mockHistory[i].Tag != "HEAD" {
-						return mockHistory[i].Tag, nil
+				for distance, h := range mockHistory[i:] {
+					if slices.ContainsFunc(tags, func(gt gitsemver.GitTag) bool { return gt.Tag == h.Tag }) {
+						return h.Tag, distance, nil
 					}
-					i++
 				}
 			}
 		}
@@ -149,6 +178,10 @@ func (mg *MockGitter) GetBranchesFromTag(repo, tag string) (branches []string, e
 	return
 }
 
+func (mg *MockGitter) GetBranchesContaining(repo, rev string) (branches []string, err error) {
+	return mg.GetBranchesFromTag(repo, rev)
+}
+
 func (mg *MockGitter) GetBuild(repo string) (string, error) {
 	if repo == "." {
 		return "build", nil
@@ -156,6 +189,23 @@ func (mg *MockGitter) GetBuild(repo string) (string, error) {
 	return "", nil
 }
 
+func (mg *MockGitter) GetCommitMessages(repo, from, to string) (messages []string, err error) {
+	if repo == "." {
+		mg.messagesFrom = from
+		messages = mg.messages
+	}
+	return
+}
+
+func (mg *MockGitter) GetCommits(repo, revRange string) (commits []string, err error) {
+	if repo == "." {
+		for _, h := range mockHistory {
+			commits = append(commits, h.Commit)
+		}
+	}
+	return
+}
+
 func (mg *MockGitter) GetHead(repo string, skip bool) (head string, err error) {
 	if !skip {
 		if commit, _, e := mg.GetHashes(repo, "HEAD"); e == nil {
@@ -165,11 +215,27 @@ func (mg *MockGitter) GetHead(repo string, skip bool) (head string, err error) {
 	return
 }
 
+func (mg *MockGitter) CountCommits(repo, rev, since, path string) (count int, err error) {
+	mg.countSince, mg.countPath = since, path
+	count = len(mockHistory)
+	if since != "" {
+		count = 3
+	}
+	if path != "" {
+		count--
+	}
+	return
+}
+
+func (mg *MockGitter) GetCommitTime(repo, rev string) (when time.Time, err error) {
+	return mg.commitTime, nil
+}
+
 func (mg *MockGitter) ResetHard(repo, commit string) (err error) {
 	return nil
 }
 
-func (mg *MockGitter) FetchTags(repo string) error {
+func (mg *MockGitter) FetchTags(repo, remote string) error {
 	return nil
 }
 
@@ -181,11 +247,15 @@ func (mg *MockGitter) DeleteTag(repo, tag string) (err error) {
 	return
 }
 
-func (mg *MockGitter) PushTag(repo, tag string) (err error) {
+func (mg *MockGitter) PushTag(repo, remote, tag, branch string) (err error) {
+	return
+}
+
+func (mg *MockGitter) UnpushTag(repo, remote, tag, branch, commit string) (err error) {
 	return
 }
 
-func (mg *MockGitter) DeleteRemoteTag(repo, tag string) (err error) {
+func (mg *MockGitter) FastForward(repo, remote, branch string) (err error) {
 	return
 }
 
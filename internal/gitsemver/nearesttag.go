@@ -0,0 +1,183 @@
+package gitsemver
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commitGraph is the history reachable from a commit.
+type commitGraph struct {
+	order   []string            // the commits, children before their parents
+	parents map[string][]string // parent commits of each commit
+	when    map[string]int64    // committer time of each commit as a Unix timestamp
+}
+
+// sortDescribe sorts the tags in the order "git describe" prefers tags on
+// the same commit: annotated tags first, then by name.
+func sortDescribe(tags []GitTag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Annotated != tags[j].Annotated {
+			return tags[i].Annotated
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+}
+
+// commitGrapher is implemented by the Gitters that can return the whole
+// history reachable from a revision at once.
+type commitGrapher interface {
+	commitGraph(repo, rev string) (g *commitGraph, err error)
+}
+
+func newCommitGraph() *commitGraph {
+	return &commitGraph{parents: map[string][]string{}, when: map[string]int64{}}
+}
+
+// walkCommitGraph returns the history reachable from the start commit, with the
+// commits in the reverse order of a depth-first postorder walk. The commits are
+// read with the commit function.
+func walkCommitGraph(start string, commit func(hash string) (*gitCommit, error)) (g *commitGraph, err error) {
+	type frame struct {
+		hash string
+		c    *gitCommit
+		next int // index of the next parent to visit
+	}
+	var c *gitCommit
+	if c, err = commit(start); err != nil {
+		return nil, err
+	}
+	var postorder []frame
+	seen := map[string]bool{start: true}
+	for stack := []frame{{hash: start, c: c}}; len(stack) > 0; {
+		top := &stack[len(stack)-1]
+		if top.next < len(top.c.parents) {
+			parent := top.c.parents[top.next]
+			top.next++
+			if !seen[parent] {
+				seen[parent] = true
+				if c, err = commit(parent); err != nil {
+					return nil, err
+				}
+				stack = append(stack, frame{hash: parent, c: c})
+			}
+			continue
+		}
+		postorder = append(postorder, *top)
+		stack = stack[:len(stack)-1]
+	}
+	g = newCommitGraph()
+	for i := len(postorder) - 1; i >= 0; i-- {
+		g.add(postorder[i].hash, postorder[i].c.when.Unix(), postorder[i].c.parents)
+	}
+	return
+}
+
+// parseRevList returns the commitGraph from the output of
+// "git rev-list --topo-order --parents --timestamp".
+func parseRevList(output string) (g *commitGraph, err error) {
+	g = newCommitGraph()
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 {
+			var when int64
+			if when, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+				return nil, err
+			}
+			g.add(fields[1], when, fields[2:])
+		}
+	}
+	return
+}
+
+func (g *commitGraph) add(commit string, when int64, parents []string) {
+	g.order = append(g.order, commit)
+	g.parents[commit] = parents
+	g.when[commit] = when
+}
+
+// head returns the commit the graph was made from, or an empty string if the graph is empty.
+func (g *commitGraph) head() (commit string) {
+	if len(g.order) > 0 {
+		commit = g.order[0]
+	}
+	return
+}
+
+// has returns true if the commit is in the graph.
+func (g *commitGraph) has(commit string) (ok bool) {
+	_, ok = g.parents[commit]
+	return
+}
+
+// reachable returns the commits reachable from commit, including itself.
+func (g *commitGraph) reachable(commit string) (seen map[string]bool) {
+	seen = map[string]bool{commit: true}
+	for stack := []string{commit}; len(stack) > 0; {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, parent := range g.parents[c] {
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return
+}
+
+// ancestors returns the number of commits reachable from commit, including itself.
+func (g *commitGraph) ancestors(commit string) (count int) {
+	return len(g.reachable(commit))
+}
+
+// countCommits returns the number of commits reachable from commit but not
+// from since, like "git rev-list --count commit ^since". An empty since means
+// all commits reachable from commit. Both must be in the graph.
+func (g *commitGraph) countCommits(commit, since string) (count int) {
+	seen := g.reachable(commit)
+	count = len(seen)
+	if since != "" {
+		for c := range g.reachable(since) {
+			if seen[c] {
+				count--
+			}
+		}
+	}
+	return
+}
+
+// nearestTag returns the tag among tags that is nearest to the commit, and
+// its distance as the number of commits reachable from the commit but not
+// from the tag, like the depth "git describe" uses.
+// Equal distances are won by the tag on the newer commit, as found first by
+// "git describe", and tags on the same commit by the first one in tags.
+// Returns an empty tag if none of the tags are reachable from the commit.
+func (g *commitGraph) nearestTag(commit string, tags []GitTag) (tag string, distance int) {
+	byCommit := map[string]string{}
+	for _, gt := range tags {
+		if _, ok := byCommit[gt.Commit]; !ok {
+			byCommit[gt.Commit] = gt.Tag
+		}
+	}
+	var best string
+	seen := g.reachable(commit)
+	pos := 0
+	for _, c := range g.order {
+		if !seen[c] {
+			continue
+		}
+		if tag != "" && pos > distance {
+			// ancestors come after their children, so the commits before
+			// pos are all unreachable from any tag from here on
+			break
+		}
+		if name, ok := byCommit[c]; ok {
+			d := len(seen) - g.ancestors(c)
+			if tag == "" || d < distance || (d == distance && g.when[c] > g.when[best]) {
+				tag, distance, best = name, d, c
+			}
+		}
+		pos++
+	}
+	return
+}
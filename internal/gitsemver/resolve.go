@@ -0,0 +1,166 @@
+package gitsemver
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VersionMatch is a commit that has a version, as found by ResolveVersion.
+type VersionMatch struct {
+	Commit string // commit hash
+	Tag    string // tag the version is based on, including TagPrefix
+	Branch string // branch the commit was found on, empty for a release version
+}
+
+// versionParts are the parts Version joins into a version string.
+type versionParts struct {
+	tag    string // tag without TagPrefix
+	branch string // cleaned branch, may be empty
+	build  string // build number, may be empty
+}
+
+// isDigits returns true if s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// parseSuffix splits the suffix of a work-in-progress version into the cleaned
+// branch and the build number. A suffix of only digits is taken to be a build.
+func parseSuffix(suffix string) (branch, build string, ok bool) {
+	var found bool
+	if branch, build, found = strings.Cut(suffix, "."); !found && isDigits(branch) {
+		branch, build = "", branch
+	}
+	ok = branch == CleanBranch(branch) && (build == "" || isDigits(build)) && (!found || (branch != "" && build != ""))
+	return
+}
+
+// parseVersion returns the ways the version string can be split into the parts
+// Version joins with the given style, for the given tags without TagPrefix.
+// The tags are needed since both tags and cleaned branches may contain '-'.
+func parseVersion(version string, style VersionStyle, tags []string) (parses []versionParts) {
+	for _, tag := range tags {
+		if version == tag {
+			parses = append(parses, versionParts{tag: tag})
+			continue
+		}
+		vi := VersionInfo{Tag: tag, Style: style}
+		base, separator := vi.versionBase()
+		if suffix, ok := strings.CutPrefix(version, base+separator); ok {
+			if branch, build, ok := parseSuffix(suffix); ok {
+				parses = append(parses, versionParts{tag: tag, branch: branch, build: build})
+			}
+		}
+	}
+	return
+}
+
+// ResolveVersion returns the commits that have the given version, as formatted
+// by VersionInfo.Version with the Style, BuildMode and TagPrefix of vs. The version
+// may include TagPrefix and have '_' in place of '+', as from SafeVersion.
+//
+// A release version resolves to the commit of its tag. A work-in-progress version
+// resolves to the commits on the first-parent history of the branches with the
+// tag whose build number is the one in the version, and with an empty branch in
+// the version any such branch is searched. Build numbers that came from a CI
+// system can't be resolved. If more than one commit matches, the matches are
+// returned with an ErrAmbiguousVersion.
+func (vs *GitSemVer) ResolveVersion(repo, version string) (matches []VersionMatch, err error) {
+	if repo, err = vs.Git.CheckGitRepo(repo); err == nil {
+		version = strings.ReplaceAll(strings.TrimPrefix(version, vs.TagPrefix), "_", "+")
+		var all []string
+		if all, err = vs.allTags(repo); err == nil {
+			tags := make([]string, len(all))
+			for i := range all {
+				tags[i] = strings.TrimPrefix(all[i], vs.TagPrefix)
+			}
+			seen := map[string]bool{}
+			for _, parts := range parseVersion(version, vs.Style, tags) {
+				var found []VersionMatch
+				if found, err = vs.resolveParts(repo, parts); err != nil {
+					return nil, err
+				}
+				for _, m := range found {
+					if !seen[m.Commit] {
+						seen[m.Commit] = true
+						matches = append(matches, m)
+					}
+				}
+			}
+			if len(matches) > 1 {
+				err = NewErrAmbiguousVersion(version, matches)
+			}
+		}
+	}
+	return
+}
+
+// resolveParts returns the commits that have the version made of parts.
+func (vs *GitSemVer) resolveParts(repo string, parts versionParts) (matches []VersionMatch, err error) {
+	tag := vs.TagPrefix + parts.tag
+	var tagged GitTag
+	if tagged, err = vs.getTreeHash(repo, tag); err == nil {
+		if parts.branch == "" && parts.build == "" {
+			return []VersionMatch{{Commit: tagged.Commit, Tag: tag}}, nil
+		}
+		var want int
+		if want, err = strconv.Atoi(parts.build); err != nil {
+			// without a build number there is nothing to find the commit by
+			return nil, nil
+		}
+		var branches []string
+		if branches, err = vs.Git.GetBranchesContaining(repo, tag); err == nil {
+			for _, branch := range branches {
+				if parts.branch == "" || CleanBranch(branch) == parts.branch {
+					var found []string
+					if found, err = vs.findBuild(repo, branch, tagged.Commit, want); err != nil {
+						return nil, err
+					}
+					for _, commit := range found {
+						var vi VersionInfo
+						if vi, err = vs.getVersion(repo, commit, branch); err != nil {
+							return nil, err
+						}
+						// a newer tag may have been merged in since
+						if vi.FullTag() == tag && vi.Build == parts.build {
+							matches = append(matches, VersionMatch{Commit: commit, Tag: tag, Branch: branch})
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// findBuild returns the commits on the first-parent history of branch since
+// tagCommit, and tagCommit itself, that have the wanted build number.
+func (vs *GitSemVer) findBuild(repo, branch, tagCommit string, want int) (commits []string, err error) {
+	build := func(commit string) (n int) {
+		var s string
+		if err == nil {
+			if s, err = vs.getBuild(repo, commit, tagCommit, nil); err == nil {
+				n, err = strconv.Atoi(s)
+			}
+		}
+		return
+	}
+	var chain []string
+	if chain, err = vs.Git.GetCommits(repo, "--first-parent ^"+tagCommit+" "+branch); err == nil {
+		// build numbers only decrease going back in history
+		for i := sort.Search(len(chain), func(i int) bool { return build(chain[i]) <= want }); i < len(chain); i++ {
+			if build(chain[i]) != want {
+				break
+			}
+			commits = append(commits, chain[i])
+		}
+		if build(tagCommit) == want {
+			commits = append(commits, tagCommit)
+		}
+	}
+	if err != nil {
+		commits = nil
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package gitsemver
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_parseVersion(t *testing.T) {
+	tags := []string{"v1.2.3", "v1.2.3-rc.1", "v1.2.3-rc", "v2.0"}
+	tests := []struct {
+		version string
+		style   VersionStyle
+		want    []versionParts
+	}{
+		{"v1.2.3", StyleDefault, []versionParts{{tag: "v1.2.3"}}},
+		{"v1.2.3-feature-x.456", StyleDefault, []versionParts{{tag: "v1.2.3", branch: "feature-x", build: "456"}}},
+		{"v1.2.3-456", StyleDefault, []versionParts{{tag: "v1.2.3", build: "456"}}},
+		{"v1.2.3-main", StyleDefault, []versionParts{{tag: "v1.2.3", branch: "main"}}},
+		{"v1.2.3-rc.1-main.7", StyleDefault, []versionParts{{tag: "v1.2.3-rc.1", branch: "main", build: "7"}}},
+		{"v1.2.3-rc-1.5", StyleDefault, []versionParts{{tag: "v1.2.3", branch: "rc-1", build: "5"}, {tag: "v1.2.3-rc", branch: "1", build: "5"}}},
+		{"v1.2.3+main.7", StyleMetadata, []versionParts{{tag: "v1.2.3", branch: "main", build: "7"}}},
+		{"v1.2.4-0.main.7", StyleNextPatch, []versionParts{{tag: "v1.2.3", branch: "main", build: "7"}}},
+		{"v1.2.3-rc.1.0.main.7", StyleNextPatch, []versionParts{{tag: "v1.2.3-rc.1", branch: "main", build: "7"}}},
+		{"v2.0.1-0.7", StyleNextPatch, []versionParts{{tag: "v2.0", build: "7"}}},
+		{"v1.2.3-Main.7", StyleDefault, nil},
+		{"v1.2.3-main.x", StyleDefault, nil},
+		{"v1.2.3-main.7.8", StyleDefault, nil},
+		{"v9.9.9", StyleDefault, nil},
+	}
+	for _, tt := range tests {
+		if got := parseVersion(tt.version, tt.style, tags); !slices.Equal(got, tt.want) {
+			t.Errorf("parseVersion(%q, %v) = %+v, want %+v", tt.version, tt.style, got, tt.want)
+		}
+	}
+}
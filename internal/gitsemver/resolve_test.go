@@ -0,0 +1,81 @@
+package gitsemver_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_VersionStringer_ResolveVersion(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	runGit(t, repo, nil, "branch", "-M", "main")
+	runGit(t, repo, nil, "config", "user.email", "test@example.com")
+	runGit(t, repo, nil, "config", "user.name", "Test")
+	commitAt(t, repo, "a.txt", "1\n", "c1", "2020-01-01T00:00:00Z")
+	runGit(t, repo, nil, "tag", "v1.0.0")
+	commitAt(t, repo, "a.txt", "2\n", "c2", "2020-01-02T00:00:00Z")
+	runGit(t, repo, nil, "checkout", "-q", "-b", "Feature/X")
+	commitAt(t, repo, "b.txt", "4\n", "c4", "2020-01-04T00:00:00Z")
+	commitAt(t, repo, "b.txt", "5\n", "c5", "2020-01-05T00:00:00Z")
+	runGit(t, repo, nil, "checkout", "-q", "main")
+	commitAt(t, repo, "a.txt", "3\n", "c3", "2020-01-03T00:00:00Z")
+	commit := func(rev string) string {
+		return strings.TrimSpace(runGit(t, repo, nil, "rev-parse", rev))
+	}
+
+	for _, mode := range []gitsemver.BuildMode{gitsemver.BuildAll, gitsemver.BuildHeight} {
+		for _, branch := range []string{"main", "Feature/X"} {
+			vs, err := gitsemver.New("git", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs.BuildMode = mode
+			versions, err := vs.GetVersions(repo, "v1.0.0.."+branch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, vi := range versions {
+				matches, err := vs.ResolveVersion(repo, vi.Version())
+				if err != nil || len(matches) != 1 || matches[0].Commit != vi.Commit || matches[0].Branch != branch {
+					t.Errorf("build mode %v: ResolveVersion(%q) = %+v, %v; want %s on %s", mode, vi.Version(), matches, err, vi.Commit, branch)
+				}
+			}
+		}
+	}
+
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := vs.ResolveVersion(repo, "v1.0.0")
+	if err != nil || len(matches) != 1 || matches[0].Commit != commit("v1.0.0") || matches[0].Branch != "" {
+		t.Errorf("ResolveVersion(v1.0.0) = %+v, %v", matches, err)
+	}
+	if matches, err = vs.ResolveVersion(repo, "v1.0.0-main.99"); err != nil || len(matches) != 0 {
+		t.Errorf("ResolveVersion(v1.0.0-main.99) = %+v, %v", matches, err)
+	}
+	// without a branch, both c3 on main and c4 on Feature/X have build 3
+	matches, err = vs.ResolveVersion(repo, "v1.0.0-3")
+	if !errors.Is(err, gitsemver.ErrAmbiguousVersion) || len(matches) != 2 {
+		t.Fatalf("ResolveVersion(v1.0.0-3) = %+v, %v", matches, err)
+	}
+	for _, m := range matches {
+		if !strings.Contains(err.Error(), m.Commit) {
+			t.Errorf("error %q doesn't list %s", err, m.Commit)
+		}
+	}
+	if matches[0].Commit != commit("Feature/X~1") || matches[1].Commit != commit("main") {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+
+	if vs, err = gitsemver.New("git", nil); err != nil {
+		t.Fatal(err)
+	}
+	vs.TagPrefix = "tools/"
+	if matches, err = vs.ResolveVersion(repo, "tools/v1.0.0"); err != nil || len(matches) != 0 {
+		t.Errorf("ResolveVersion(tools/v1.0.0) = %+v, %v", matches, err)
+	}
+}
@@ -1,20 +1,23 @@
 package gitsemver
 
 import (
+	"strconv"
 	"strings"
 
 	xmodsemver "golang.org/x/mod/semver"
 )
 
 // canonicalSemverTag converts relaxed git tag forms like "1", "1.2", "v1.2.3"
-// into strict canonical semver for validation/comparison.
+// and prerelease tags like "v1.3.0-rc.1" into strict canonical semver for
+// validation/comparison.
 func canonicalSemverTag(tag string) (canonical string, ok bool) {
 	if tag = strings.TrimSpace(tag); tag != "" {
 		if !strings.HasPrefix(tag, "v") {
 			tag = "v" + tag
 		}
-		// Git tags are intentionally limited to numeric MAJOR[.MINOR][.PATCH] forms.
-		if xmodsemver.Prerelease(tag) == "" && xmodsemver.Build(tag) == "" {
+		// Git tags are intentionally limited to numeric MAJOR[.MINOR][.PATCH] forms,
+		// optionally followed by a prerelease suffix. Build metadata is not allowed.
+		if xmodsemver.Build(tag) == "" {
 			canonical = xmodsemver.Canonical(tag)
 		}
 	}
@@ -27,8 +30,69 @@ func isSemverTag(tag string) bool {
 	return ok
 }
 
+// isPrefixedSemverTag returns true if tag is prefix followed by a semver tag.
+func isPrefixedSemverTag(prefix, tag string) bool {
+	version, ok := strings.CutPrefix(tag, prefix)
+	return ok && isSemverTag(version)
+}
+
+// escapeGlob escapes the characters that git treats as wildcards in tag patterns.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 func semverTagGreater(leftTag, rightTag string) bool {
 	leftCanonical, _ := canonicalSemverTag(leftTag)
 	rightCanonical, _ := canonicalSemverTag(rightTag)
 	return xmodsemver.Compare(leftCanonical, rightCanonical) > 0
 }
+
+// splitSemverTag splits a semver tag into its "v" prefix, the numeric
+// MAJOR.MINOR.PATCH core and the prerelease suffix without the leading "-".
+// Missing MINOR or PATCH components are returned as zero.
+func splitSemverTag(tag string) (prefix string, core [3]int, prerelease string, ok bool) {
+	if _, ok = canonicalSemverTag(tag); ok {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "v") {
+			prefix = "v"
+			tag = tag[1:]
+		}
+		var coreText string
+		coreText, prerelease, _ = strings.Cut(tag, "-")
+		for i, s := range strings.Split(coreText, ".") {
+			var err error
+			if core[i], err = strconv.Atoi(s); err != nil {
+				ok = false
+				break
+			}
+		}
+	}
+	return
+}
+
+// isPrereleaseTag returns true if tag is a semver tag with a prerelease part.
+func isPrereleaseTag(tag string) bool {
+	_, _, prerelease, ok := splitSemverTag(tag)
+	return ok && prerelease != ""
+}
+
+func formatSemverCore(prefix string, core [3]int) string {
+	return prefix + strconv.Itoa(core[0]) + "." + strconv.Itoa(core[1]) + "." + strconv.Itoa(core[2])
+}
+
+func bumpSemverCore(core [3]int, bump Bump) [3]int {
+	switch bump {
+	case BumpMajor:
+		return [3]int{core[0] + 1, 0, 0}
+	case BumpMinor:
+		return [3]int{core[0], core[1] + 1, 0}
+	}
+	return [3]int{core[0], core[1], core[2] + 1}
+}
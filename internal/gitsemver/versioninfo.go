@@ -1,47 +1,71 @@
 package gitsemver
 
 import (
+	"errors"
 	"fmt"
 	"go/token"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
 )
 
 var (
 	reNonSemVerPreRelease = regexp.MustCompile(`[^0-9A-Za-z-]`)
+	rePrereleaseChannel   = regexp.MustCompile(`^[A-Za-z][0-9A-Za-z-]*$`)
 )
 
 type GitTag struct {
-	Tag    string
-	Commit string
-	Tree   string
+	Tag       string
+	Commit    string
+	Tree      string
+	Annotated bool // true if the tag is an annotated tag object, false if lightweight
 }
 
 type VersionInfo struct {
-	Tag       string   // git tag, e.g. "v1.2.3"
-	Branch    string   // git branch, e.g. "Special--Branch"
-	Build     string   // git or CI build number, e.g. "456"
-	SameTree  bool     // true if tree hash is identical
-	IsRelease bool     // true if the branch is a release branch
-	Tags      []GitTag // all tags and their tree hashes
+	Tag        string       // git tag without TagPrefix, e.g. "v1.2.3"
+	TagPrefix  string       // tag prefix, e.g. "tools/cli/" for a Go module in that directory
+	Branch     string       // git branch, e.g. "Special--Branch"
+	Build      string       // git or CI build number, e.g. "456"
+	SameTree   bool         // true if tree hash is identical
+	IsRelease  bool         // true if the branch is a release branch
+	Tags       []GitTag     // all tags (including TagPrefix) and their tree hashes
+	Style      VersionStyle // how Version formats work-in-progress versions
+	Commit     string       // HEAD commit hash
+	Tree       string       // HEAD tree hash
+	TagCommit  string       // commit hash of Tag, HEAD if Tag was just bumped, empty if Tag doesn't exist
+	CommitTime time.Time    // HEAD committer time
+	Dirty      bool         // true if there are uncommitted changes, ignoring untracked files
+	Line       *VersionLine // version line of the release branch, e.g. 1.2 for "release/1.2", or nil
+}
+
+func readModulePath(repo string) (modPath string, err error) {
+	var b []byte
+	if b, err = os.ReadFile(filepath.Join(repo, "go.mod")); /*#nosec G304*/ err == nil {
+		for _, s := range strings.Split(string(b), "\n") {
+			s = strings.TrimSpace(s)
+			fields := strings.Fields(s)
+			if len(fields) >= 2 && fields[0] == "module" {
+				modPath = fields[1]
+				break
+			}
+		}
+	}
+	return
 }
 
 func findPackageName(repo, s string) (pkgName string, err error) {
 	pkgName = s
 	if pkgName == "" {
-		var b []byte
-		if b, err = os.ReadFile(filepath.Join(repo, "go.mod")); /*#nosec G304*/ err == nil {
-			for _, s := range strings.Split(string(b), "\n") {
-				s = strings.TrimSpace(s)
-				fields := strings.Fields(s)
-				if len(fields) >= 2 && fields[0] == "module" {
-					pkgName = LastName(fields[1])
-					break
-				}
-			}
+		var modPath string
+		if modPath, err = readModulePath(repo); err == nil {
+			pkgName = LastName(modPath)
 		}
 	}
 	if err == nil && !token.IsIdentifier(pkgName) {
@@ -63,6 +87,12 @@ const PkgVersion = %q
 // with the given pkgName in all lower case and the contents of Version.
 // If the pkgName isn't a valid Go identifier, an error is returned.
 func (vi *VersionInfo) GoPackage(repo, pkgName, packageName, createTag string) (retv string, err error) {
+	return vi.GoPackageFields(repo, pkgName, packageName, createTag, 0)
+}
+
+// GoPackageFields is like GoPackage, but also defines the constants
+// selected by fields after "PkgVersion".
+func (vi *VersionInfo) GoPackageFields(repo, pkgName, packageName, createTag string, fields GoFields) (retv string, err error) {
 	pkgName, err = findPackageName(repo, pkgName)
 	if err == nil {
 		if packageName == "" {
@@ -81,20 +111,64 @@ func (vi *VersionInfo) GoPackage(repo, pkgName, packageName, createTag string) (
 			packageName,
 			pkgName,
 			createTag)
+		retv += vi.goPackageFields(fields)
 	}
 	return
 }
 
+func (vi *VersionInfo) goPackageFields(fields GoFields) string {
+	var sb strings.Builder
+	if fields&GoFieldCommit != 0 {
+		fmt.Fprintf(&sb, "const PkgCommit = %q\n", vi.Commit)
+	}
+	if fields&GoFieldTree != 0 {
+		fmt.Fprintf(&sb, "const PkgTree = %q\n", vi.Tree)
+	}
+	if fields&GoFieldBranch != 0 {
+		fmt.Fprintf(&sb, "const PkgBranch = %q\n", vi.Branch)
+	}
+	if fields&GoFieldBuild != 0 {
+		fmt.Fprintf(&sb, "const PkgBuild = %q\n", vi.Build)
+	}
+	if fields&GoFieldCommitTime != 0 {
+		var when string
+		if !vi.CommitTime.IsZero() {
+			when = vi.CommitTime.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&sb, "const PkgCommitTime = %q\n", when)
+	}
+	if fields&GoFieldDirty != 0 {
+		fmt.Fprintf(&sb, "const PkgDirty = %v\n", vi.Dirty)
+	}
+	return sb.String()
+}
+
+// FullTag returns the git tag including the TagPrefix, e.g. "tools/cli/v1.2.3".
+func (vi *VersionInfo) FullTag() string {
+	return vi.TagPrefix + vi.Tag
+}
+
+// tagNames returns the names of Tags that start with TagPrefix, without the prefix.
+func (vi *VersionInfo) tagNames() (names []string) {
+	for _, gt := range vi.Tags {
+		if name, ok := strings.CutPrefix(gt.Tag, vi.TagPrefix); ok {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// HasTag returns true if Tags has the given tag, ignoring TagPrefix.
 func (vi *VersionInfo) HasTag(tag string) bool {
 	tagCanonical, tagIsSemver := canonicalSemverTag(tag)
-	for _, gt := range vi.Tags {
-		if gt.Tag == tag {
+	for _, name := range vi.tagNames() {
+		if name == tag {
 			return true
 		}
 		// Treat semver tags as equivalent when their canonical forms match,
 		// so v1.3, v1.3.0, and 1.3.0 all collide.
 		if tagIsSemver {
-			if gtCanonical, ok := canonicalSemverTag(gt.Tag); ok && gtCanonical == tagCanonical {
+			if gtCanonical, ok := canonicalSemverTag(name); ok && gtCanonical == tagCanonical {
 				return true
 			}
 		}
@@ -102,6 +176,13 @@ func (vi *VersionInfo) HasTag(tag string) bool {
 	return false
 }
 
+// tagHead makes the current tag one to be created on HEAD, returning it.
+func (vi *VersionInfo) tagHead() string {
+	vi.SameTree = true
+	vi.TagCommit = vi.Commit
+	return vi.Tag
+}
+
 // IncPatch increments the patch level of the version, returning the new tag.
 func (vi *VersionInfo) IncPatch() string {
 	baseTag := vi.Tag
@@ -130,8 +211,7 @@ func (vi *VersionInfo) IncPatch() string {
 			break
 		}
 	}
-	vi.SameTree = true
-	return vi.Tag
+	return vi.tagHead()
 }
 
 // IncMinor increments the minor level of the version, returning the new tag.
@@ -177,10 +257,175 @@ func (vi *VersionInfo) IncMinor() string {
 			break
 		}
 	}
-	vi.SameTree = true
+	return vi.tagHead()
+}
+
+// IncMajor increments the major level of the version, returning the new tag.
+func (vi *VersionInfo) IncMajor() string {
+	baseTag := vi.Tag
+	// Ignore prerelease/build suffixes when incrementing the major level.
+	if idx := strings.IndexAny(baseTag, "-+"); idx > -1 {
+		if core := baseTag[:idx]; isSemverTag(core) {
+			baseTag = core
+		}
+	}
+	if !isSemverTag(baseTag) {
+		vi.SameTree = true
+		return vi.Tag
+	}
+	prefix := ""
+	if strings.HasPrefix(baseTag, "v") {
+		prefix = "v"
+	}
+	parts := strings.Split(strings.TrimPrefix(baseTag, "v"), ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		vi.SameTree = true
+		return vi.Tag
+	}
+	// Keep the same number of components as the source tag.
+	zeroes := strings.Repeat(".0", len(parts)-1)
+	for {
+		major++
+		vi.Tag = prefix + strconv.Itoa(major) + zeroes
+		if !vi.HasTag(vi.Tag) {
+			break
+		}
+	}
+	return vi.tagHead()
+}
+
+// IsPrereleaseChannel returns true if channel can be used as the
+// prerelease channel name in IncPrerelease, e.g. "rc", "beta" or "alpha".
+func IsPrereleaseChannel(channel string) bool {
+	return rePrereleaseChannel.MatchString(channel)
+}
+
+// IsPrerelease returns true if the tag is a semver tag with a prerelease part.
+func (vi *VersionInfo) IsPrerelease() bool {
+	return isPrereleaseTag(vi.Tag)
+}
+
+// IncPrerelease increments the version to the next prerelease on the given
+// channel, returning the new tag, e.g. "v1.3.0-rc.1" for IncPrerelease(BumpMinor, "rc")
+// on "v1.2.3". The counter continues from the highest existing tag on that channel,
+// so a second run gives "v1.3.0-rc.2".
+//
+// If the current tag is already a prerelease whose version core satisfies the
+// requested bump, the core is kept. BumpNone is treated as BumpPatch unless the
+// current tag is a prerelease.
+func (vi *VersionInfo) IncPrerelease(bump Bump, channel string) string {
+	prefix, core, prerelease, ok := splitSemverTag(vi.Tag)
+	if !ok || !IsPrereleaseChannel(channel) {
+		vi.SameTree = true
+		return vi.Tag
+	}
+	bump = max(bump, BumpPatch)
+	keepCore := prerelease != ""
+	switch bump {
+	case BumpMinor:
+		keepCore = keepCore && core[2] == 0
+	case BumpMajor:
+		keepCore = keepCore && core[1] == 0 && core[2] == 0
+	}
+	if !keepCore {
+		core = bumpSemverCore(core, bump)
+	}
+	// Never create a prerelease for a version that is already released.
+	for vi.HasTag(formatSemverCore(prefix, core)) {
+		core = bumpSemverCore(core, bump)
+	}
+	counter := 0
+	for _, name := range vi.tagNames() {
+		if _, gtCore, gtPrerelease, ok := splitSemverTag(name); ok && gtCore == core {
+			if n, found := strings.CutPrefix(gtPrerelease, channel+"."); found {
+				if num, err := strconv.Atoi(n); err == nil && num > counter {
+					counter = num
+				}
+			}
+		}
+	}
+	vi.Tag = formatSemverCore(prefix, core) + "-" + channel + "." + strconv.Itoa(counter+1)
+	return vi.tagHead()
+}
+
+// Promote changes a prerelease tag into the corresponding release tag,
+// e.g. "v1.3.0-rc.2" becomes "v1.3.0", and returns it. If the tag is not
+// a prerelease or the release tag already exists, it returns an empty
+// string and leaves the VersionInfo unchanged.
+func (vi *VersionInfo) Promote() (tag string) {
+	if prefix, core, prerelease, ok := splitSemverTag(vi.Tag); ok && prerelease != "" {
+		if release := formatSemverCore(prefix, core); !vi.HasTag(release) {
+			vi.Tag = release
+			tag = vi.tagHead()
+		}
+	}
+	return
+}
+
+// Inc increments the version by the given level, returning the new tag.
+// If bump is BumpNone the tag is returned unchanged.
+func (vi *VersionInfo) Inc(bump Bump) string {
+	switch bump {
+	case BumpPatch:
+		return vi.IncPatch()
+	case BumpMinor:
+		return vi.IncMinor()
+	case BumpMajor:
+		return vi.IncMajor()
+	}
 	return vi.Tag
 }
 
+// CheckModuleMajor returns an error if the repo has a go.mod whose module
+// path does not have the major version suffix required for the given tag,
+// for example a tag of "v2.0.0" requires the module path to end in "/v2".
+// The Go toolchain rejects such tags, so they should not be created.
+// Returns nil if tag is empty, not a semver tag, or if there is no go.mod.
+func CheckModuleMajor(repo, tag string) (err error) {
+	if canonical, ok := canonicalSemverTag(tag); ok {
+		var modPath string
+		if modPath, err = readModulePath(repo); err == nil {
+			if modPath != "" {
+				if _, pathMajor, ok := module.SplitPathVersion(modPath); ok {
+					if module.CheckPathMajor(canonical, pathMajor) != nil {
+						err = NewErrModuleMajor(modPath, tag)
+					}
+				}
+			}
+		} else if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	return
+}
+
+// ModuleTagPrefix returns the tag prefix the Go toolchain expects for the
+// module in moduleDir, which is the directory relative to the repo root
+// followed by a slash, e.g. "tools/cli/". A trailing major version directory
+// matching the module path is not part of the prefix, so a module
+// "example.com/repo/tools/cli/v2" in "tools/cli/v2" also uses "tools/cli/".
+// Returns an empty string for a module in the repo root.
+func ModuleTagPrefix(repo, moduleDir string) (prefix string, err error) {
+	var rel string
+	if rel, err = filepath.Rel(repo, moduleDir); err == nil {
+		if rel = filepath.ToSlash(rel); rel == ".." || strings.HasPrefix(rel, "../") {
+			err = fmt.Errorf("%q is not inside the repository %q", moduleDir, repo)
+		} else if rel != "." {
+			var modPath string
+			if modPath, err = readModulePath(moduleDir); err == nil {
+				if _, pathMajor, ok := module.SplitPathVersion(modPath); ok && pathMajor != "" && path.Base(rel) == pathMajor[1:] {
+					rel = path.Dir(rel)
+				}
+				if rel != "." {
+					prefix = rel + "/"
+				}
+			}
+		}
+	}
+	return
+}
+
 func CleanBranch(branch string) string {
 	// SemVer pre-release identifiers only allow [0-9A-Za-z-].
 	branch = reNonSemVerPreRelease.ReplaceAllString(branch, "-")
@@ -197,7 +442,9 @@ func CleanBranch(branch string) string {
 	return branch
 }
 
-// Version returns the composite version, e.g. "v1.2.3-mybranch.456"
+// Version returns the composite version, e.g. "v1.2.3-mybranch.456",
+// "v1.2.4-0.mybranch.456" if Style is StyleNextPatch or
+// "v1.2.3+mybranch.456" if Style is StyleMetadata.
 func (vi *VersionInfo) Version() (version string) {
 	if vi.Tag != "" {
 		version = vi.Tag
@@ -209,10 +456,39 @@ func (vi *VersionInfo) Version() (version string) {
 				}
 				suffix += vi.Build
 			}
-			if suffix != "" {
-				version += "-" + suffix
+			base, separator := vi.versionBase()
+			if version = base; suffix != "" {
+				version += separator + suffix
 			}
 		}
 	}
 	return
 }
+
+// versionBase returns what Version puts before the branch and build
+// suffix of a work-in-progress version, and the separator between them.
+func (vi *VersionInfo) versionBase() (base, separator string) {
+	base, separator = vi.Tag, "-"
+	switch vi.Style {
+	case StyleMetadata:
+		separator = "+"
+	case StyleNextPatch:
+		if prefix, core, prerelease, ok := splitSemverTag(vi.Tag); ok {
+			// Like Go pseudo-versions: a release tag gets the next patch
+			// level, a prerelease tag gets an extra ".0" identifier.
+			if prerelease == "" {
+				base = formatSemverCore(prefix, bumpSemverCore(core, BumpPatch)) + "-0"
+			} else {
+				base += ".0"
+			}
+			separator = "."
+		}
+	}
+	return
+}
+
+// SafeVersion returns Version with any "+" replaced by "_", for use
+// where "+" is not allowed, such as OCI image tags.
+func (vi *VersionInfo) SafeVersion() string {
+	return strings.ReplaceAll(vi.Version(), "+", "_")
+}
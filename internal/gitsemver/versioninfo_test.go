@@ -1,10 +1,13 @@
 package gitsemver_test
 
 import (
+	"errors"
+	"go/format"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
 )
@@ -42,6 +45,50 @@ func Test_VersionInfo_GoPackage(t *testing.T) {
 	}
 }
 
+func Test_VersionInfo_GoPackageFields(t *testing.T) {
+	vi := &gitsemver.VersionInfo{
+		Tag:        "v1.2.3",
+		Branch:     "mybranch",
+		Build:      "456",
+		Commit:     "commit-7",
+		Tree:       "tree-7",
+		CommitTime: time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("X", 3600)),
+		Dirty:      true,
+	}
+	plain, err := vi.GoPackage("../..", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := vi.GoPackageFields("../..", "", "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, plain, txt)
+
+	txt, err = vi.GoPackageFields("../..", "", "", "", gitsemver.GoFieldsAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := plain + `const PkgCommit = "commit-7"
+const PkgTree = "tree-7"
+const PkgBranch = "mybranch"
+const PkgBuild = "456"
+const PkgCommitTime = "2024-05-06T06:08:09Z"
+const PkgDirty = true
+`
+	isEqual(t, want, txt)
+	if _, err = format.Source([]byte(txt)); err != nil {
+		t.Error(err)
+	}
+
+	vi.CommitTime = time.Time{}
+	txt, err = vi.GoPackageFields("../..", "", "", "", gitsemver.GoFieldCommitTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, plain+"const PkgCommitTime = \"\"\n", txt)
+}
+
 func Test_VersionInfo_GoPackage_ModuleWithInlineComment(t *testing.T) {
 	repo := t.TempDir()
 	goMod := "module example.com/my_pkg // inline comment\n\ngo 1.25\n"
@@ -270,3 +317,228 @@ func Test_VersionInfo_Version_UsesSemverSafeBranch(t *testing.T) {
 		t.Fatalf("expected semver-safe branch in version, got %q", got)
 	}
 }
+
+func Test_VersionInfo_IncMajor_Mappings(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "v1", want: "v2"},
+		{in: "v1.2", want: "v2.0"},
+		{in: "v1.2.3", want: "v2.0.0"},
+		{in: "0.4.1", want: "1.0.0"},
+		{in: "v1.2.3-rc.1", want: "v2.0.0"},
+	}
+	for _, tt := range tests {
+		vi := &gitsemver.VersionInfo{Tag: tt.in}
+		if got := vi.IncMajor(); got != tt.want {
+			t.Fatalf("IncMajor(%q): expected %q, got %q", tt.in, tt.want, got)
+		}
+		if !vi.SameTree {
+			t.Fatalf("IncMajor(%q): expected SameTree=true", tt.in)
+		}
+	}
+}
+
+func Test_VersionInfo_IncMajor_AvoidsEquivalentCollisions(t *testing.T) {
+	vi := &gitsemver.VersionInfo{
+		Tag: "v1.2.3",
+		Tags: []gitsemver.GitTag{
+			{Tag: "v2"},
+			{Tag: "3.0.0"},
+		},
+	}
+	if got := vi.IncMajor(); got != "v4.0.0" {
+		t.Fatalf("expected v4.0.0, got %q", got)
+	}
+}
+
+func Test_VersionInfo_IncMajor_InvalidTagNoLoop(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "not-a-semver-tag"}
+	if got := vi.IncMajor(); got != "not-a-semver-tag" {
+		t.Fatalf("expected unchanged tag, got %q", got)
+	}
+	vi = &gitsemver.VersionInfo{Tag: "v9999999999999999999999999.1.2"}
+	if got := vi.IncMajor(); got != "v9999999999999999999999999.1.2" {
+		t.Fatalf("expected unchanged overflow major tag, got %q", got)
+	}
+}
+
+func Test_CheckModuleMajor(t *testing.T) {
+	tests := []struct {
+		module string
+		tag    string
+		ok     bool
+	}{
+		{module: "example.com/foo", tag: "v1.2.3", ok: true},
+		{module: "example.com/foo", tag: "v0.1.0", ok: true},
+		{module: "example.com/foo", tag: "v2.0.0", ok: false},
+		{module: "example.com/foo/v2", tag: "v2.0.0", ok: true},
+		{module: "example.com/foo/v2", tag: "v3", ok: false},
+		{module: "example.com/foo/v2", tag: "v1.0.0", ok: false},
+		{module: "gopkg.in/foo.v2", tag: "v2.1.0", ok: true},
+		{module: "example.com/foo", tag: "not-a-semver-tag", ok: true},
+		{module: "example.com/foo", tag: "", ok: true},
+	}
+	for _, tt := range tests {
+		repo := t.TempDir()
+		goMod := "module " + tt.module + "\n\ngo 1.24\n"
+		if err := os.WriteFile(filepath.Join(repo, "go.mod"), []byte(goMod), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := gitsemver.CheckModuleMajor(repo, tt.tag)
+		if tt.ok && err != nil {
+			t.Errorf("CheckModuleMajor(%q, %q): unexpected error %v", tt.module, tt.tag, err)
+		}
+		if !tt.ok && !errors.Is(err, gitsemver.ErrModuleMajor) {
+			t.Errorf("CheckModuleMajor(%q, %q): expected ErrModuleMajor, got %v", tt.module, tt.tag, err)
+		}
+	}
+}
+
+func Test_CheckModuleMajor_NoGoMod(t *testing.T) {
+	if err := gitsemver.CheckModuleMajor(t.TempDir(), "v2.0.0"); err != nil {
+		t.Fatalf("expected no error without go.mod, got %v", err)
+	}
+}
+
+func Test_VersionInfo_IncPrerelease(t *testing.T) {
+	tests := []struct {
+		tag     string
+		tags    []string
+		bump    gitsemver.Bump
+		channel string
+		want    string
+	}{
+		{tag: "v1.2.3", bump: gitsemver.BumpMinor, channel: "rc", want: "v1.3.0-rc.1"},
+		{tag: "v1.2.3", bump: gitsemver.BumpNone, channel: "rc", want: "v1.2.4-rc.1"},
+		{tag: "v1.2.3", bump: gitsemver.BumpMajor, channel: "beta", want: "v2.0.0-beta.1"},
+		{tag: "1.2", bump: gitsemver.BumpPatch, channel: "alpha", want: "1.2.1-alpha.1"},
+		{tag: "v1.3.0-rc.1", tags: []string{"v1.3.0-rc.1"}, bump: gitsemver.BumpMinor, channel: "rc", want: "v1.3.0-rc.2"},
+		{tag: "v1.3.0-rc.1", tags: []string{"v1.3.0-rc.1"}, bump: gitsemver.BumpNone, channel: "rc", want: "v1.3.0-rc.2"},
+		{tag: "v1.3.0-rc.9", tags: []string{"v1.3.0-rc.9", "v1.3.0-rc.10", "v1.3.0-beta.20"}, channel: "rc", want: "v1.3.0-rc.11"},
+		{tag: "v1.3.0-beta.2", tags: []string{"v1.3.0-beta.2"}, bump: gitsemver.BumpMinor, channel: "rc", want: "v1.3.0-rc.1"},
+		{tag: "v1.3.1-rc.1", tags: []string{"v1.3.1-rc.1"}, bump: gitsemver.BumpMinor, channel: "rc", want: "v1.4.0-rc.1"},
+		{tag: "v1.3.0-rc.1", tags: []string{"v1.3.0-rc.1"}, bump: gitsemver.BumpMajor, channel: "rc", want: "v2.0.0-rc.1"},
+		{tag: "v1.2.3", tags: []string{"v1.3.0"}, bump: gitsemver.BumpMinor, channel: "rc", want: "v1.4.0-rc.1"},
+	}
+	for _, tt := range tests {
+		vi := &gitsemver.VersionInfo{Tag: tt.tag}
+		for _, tag := range tt.tags {
+			vi.Tags = append(vi.Tags, gitsemver.GitTag{Tag: tag})
+		}
+		if got := vi.IncPrerelease(tt.bump, tt.channel); got != tt.want {
+			t.Errorf("IncPrerelease(%q, %v, %q): expected %q, got %q", tt.tag, tt.bump, tt.channel, tt.want, got)
+		}
+		if !vi.SameTree {
+			t.Errorf("IncPrerelease(%q): expected SameTree=true", tt.tag)
+		}
+	}
+}
+
+func Test_VersionInfo_IncPrerelease_InvalidInputNoChange(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "not-a-semver-tag"}
+	if got := vi.IncPrerelease(gitsemver.BumpPatch, "rc"); got != "not-a-semver-tag" {
+		t.Fatalf("expected unchanged tag, got %q", got)
+	}
+	vi = &gitsemver.VersionInfo{Tag: "v1.2.3"}
+	if got := vi.IncPrerelease(gitsemver.BumpPatch, "r.c"); got != "v1.2.3" {
+		t.Fatalf("expected unchanged tag for invalid channel, got %q", got)
+	}
+}
+
+func Test_VersionInfo_Promote(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "v1.3.0-rc.2", Tags: []gitsemver.GitTag{{Tag: "v1.3.0-rc.2"}}}
+	isTrue(t, vi.IsPrerelease())
+	isEqual(t, "v1.3.0", vi.Promote())
+	isEqual(t, "v1.3.0", vi.Tag)
+	isTrue(t, !vi.IsPrerelease())
+
+	vi = &gitsemver.VersionInfo{Tag: "v1.3.0"}
+	isEqual(t, "", vi.Promote())
+	isEqual(t, "v1.3.0", vi.Tag)
+
+	vi = &gitsemver.VersionInfo{Tag: "v1.3.0-rc.2", Tags: []gitsemver.GitTag{{Tag: "v1.3"}}}
+	isEqual(t, "", vi.Promote())
+	isEqual(t, "v1.3.0-rc.2", vi.Tag)
+}
+
+func Test_VersionInfo_HasTag_Prerelease(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tags: []gitsemver.GitTag{{Tag: "v1.3.0-rc.1"}}}
+	isTrue(t, vi.HasTag("v1.3.0-rc.1"))
+	isTrue(t, vi.HasTag("1.3.0-rc.1"))
+	isTrue(t, !vi.HasTag("v1.3.0"))
+	isTrue(t, !vi.HasTag("v1.3.0-rc.2"))
+}
+
+func Test_IsPrereleaseChannel(t *testing.T) {
+	isTrue(t, gitsemver.IsPrereleaseChannel("rc"))
+	isTrue(t, gitsemver.IsPrereleaseChannel("beta-2"))
+	isTrue(t, !gitsemver.IsPrereleaseChannel(""))
+	isTrue(t, !gitsemver.IsPrereleaseChannel("1rc"))
+	isTrue(t, !gitsemver.IsPrereleaseChannel("r.c"))
+}
+
+func Test_VersionInfo_TagPrefix(t *testing.T) {
+	vi := &gitsemver.VersionInfo{
+		Tag:       "v1.2.3",
+		TagPrefix: "tools/cli/",
+		Tags: []gitsemver.GitTag{
+			{Tag: "tools/cli/v1.2.3"},
+			{Tag: "tools/cli/v1.2.4-rc.1"},
+			{Tag: "v1.2.4"},
+		},
+	}
+	isEqual(t, "tools/cli/v1.2.3", vi.FullTag())
+	isTrue(t, vi.HasTag("v1.2.3"))
+	isTrue(t, vi.HasTag("1.2.3"))
+	isTrue(t, !vi.HasTag("v1.2.4"))
+	isTrue(t, !vi.HasTag("tools/cli/v1.2.3"))
+	isEqual(t, "v1.2.4-rc.2", vi.IncPrerelease(gitsemver.BumpPatch, "rc"))
+	vi.Tag = "v1.2.3"
+	isEqual(t, "v1.2.4", vi.IncPatch())
+	isEqual(t, "tools/cli/v1.2.4", vi.FullTag())
+}
+
+func Test_ModuleTagPrefix(t *testing.T) {
+	repo := t.TempDir()
+	writeGoMod := func(dir, module string) string {
+		t.Helper()
+		dir = filepath.Join(repo, filepath.FromSlash(dir))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+module+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	tests := []struct {
+		dir    string
+		module string
+		want   string
+	}{
+		{dir: ".", module: "example.com/repo", want: ""},
+		{dir: "v2", module: "example.com/repo/v2", want: ""},
+		{dir: "tools/cli", module: "example.com/repo/tools/cli", want: "tools/cli/"},
+		{dir: "tools/cli/v2", module: "example.com/repo/tools/cli/v2", want: "tools/cli/"},
+		{dir: "tools/v2", module: "example.com/repo/tools/v2", want: "tools/"},
+		{dir: "lib/v2", module: "example.com/repo/lib/v2/x", want: "lib/v2/"},
+	}
+	for _, tt := range tests {
+		dir := writeGoMod(tt.dir, tt.module)
+		prefix, err := gitsemver.ModuleTagPrefix(repo, dir)
+		if err != nil {
+			t.Errorf("ModuleTagPrefix(%q): %v", tt.dir, err)
+		}
+		if prefix != tt.want {
+			t.Errorf("ModuleTagPrefix(%q) = %q, want %q", tt.dir, prefix, tt.want)
+		}
+	}
+	if _, err := gitsemver.ModuleTagPrefix(repo, filepath.Dir(repo)); err == nil {
+		t.Error("expected error for directory outside the repository")
+	}
+	if _, err := gitsemver.ModuleTagPrefix(repo, filepath.Join(repo, "missing")); err == nil {
+		t.Error("expected error for directory without go.mod")
+	}
+}
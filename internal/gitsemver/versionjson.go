@@ -0,0 +1,58 @@
+package gitsemver
+
+import "encoding/json"
+
+// VersionJSON is the JSON representation of a VersionInfo.
+// Field names are stable; new fields may be added.
+type VersionJSON struct {
+	Version     string `json:"version"`      // composite version, e.g. "v1.2.3-mybranch.456"
+	Tag         string `json:"tag"`          // git tag without prefix, e.g. "v1.2.3"
+	TagPrefix   string `json:"tag_prefix"`   // tag prefix, e.g. "tools/cli/"
+	Branch      string `json:"branch"`       // git branch, e.g. "MyBranch"
+	CleanBranch string `json:"clean_branch"` // branch as used in the version, e.g. "mybranch"
+	Build       string `json:"build"`        // git or CI build number, e.g. "456"
+	SameTree    bool   `json:"same_tree"`    // true if the tag tree matches HEAD and there are no uncommitted changes
+	IsRelease   bool   `json:"is_release"`   // true if the branch is a release branch
+	Commit      string `json:"commit"`       // HEAD commit hash
+	Tree        string `json:"tree"`         // HEAD tree hash
+	TagCommit   string `json:"tag_commit"`   // commit hash of the tag, empty if the tag doesn't exist
+}
+
+// VersionJSON returns the JSON representation of the VersionInfo.
+func (vi *VersionInfo) VersionJSON() VersionJSON {
+	return VersionJSON{
+		Version:     vi.Version(),
+		Tag:         vi.Tag,
+		TagPrefix:   vi.TagPrefix,
+		Branch:      vi.Branch,
+		CleanBranch: CleanBranch(vi.Branch),
+		Build:       vi.Build,
+		SameTree:    vi.SameTree,
+		IsRelease:   vi.IsRelease,
+		Commit:      vi.Commit,
+		Tree:        vi.Tree,
+		TagCommit:   vi.TagCommit,
+	}
+}
+
+// JSON returns the VersionJSON for the VersionInfo as indented JSON text.
+func (vi *VersionInfo) JSON() (retv string, err error) {
+	var b []byte
+	if b, err = json.MarshalIndent(vi.VersionJSON(), "", "  "); err == nil {
+		retv = string(b)
+	}
+	return
+}
+
+// VersionsJSON returns the VersionJSON for each of the versions as an indented JSON array.
+func VersionsJSON(versions []VersionInfo) (retv string, err error) {
+	list := make([]VersionJSON, len(versions))
+	for i := range versions {
+		list[i] = versions[i].VersionJSON()
+	}
+	var b []byte
+	if b, err = json.MarshalIndent(list, "", "  "); err == nil {
+		retv = string(b)
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package gitsemver_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_VersionInfo_JSON(t *testing.T) {
+	vi := &gitsemver.VersionInfo{
+		Tag:       "v1.2.3",
+		Branch:    "My/Branch",
+		Build:     "456",
+		Commit:    "commit-7",
+		Tree:      "tree-7",
+		TagCommit: "commit-6",
+	}
+	txt, err := vi.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err = json.Unmarshal([]byte(txt), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"version":      "v1.2.3-my-branch.456",
+		"tag":          "v1.2.3",
+		"tag_prefix":   "",
+		"branch":       "My/Branch",
+		"clean_branch": "my-branch",
+		"build":        "456",
+		"same_tree":    false,
+		"is_release":   false,
+		"commit":       "commit-7",
+		"tree":         "tree-7",
+		"tag_commit":   "commit-6",
+	}
+	isEqual(t, len(want), len(got))
+	for k, v := range want {
+		isEqual(t, v, got[k])
+	}
+}
+
+func Test_VersionInfo_VersionJSON_Release(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "v1.2.3", Branch: "main", Build: "456", SameTree: true, IsRelease: true}
+	vj := vi.VersionJSON()
+	isEqual(t, "v1.2.3", vj.Version)
+	isTrue(t, vj.SameTree)
+	isTrue(t, vj.IsRelease)
+}
+
+func Test_VersionsJSON(t *testing.T) {
+	txt, err := gitsemver.VersionsJSON([]gitsemver.VersionInfo{
+		{Tag: "v1.2.3", Branch: "main", SameTree: true, IsRelease: true, Commit: "commit-7"},
+		{Tag: "v1.2.2", Branch: "main", Build: "6", IsRelease: true, Commit: "commit-6"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []gitsemver.VersionJSON
+	if err = json.Unmarshal([]byte(txt), &got); err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, 2, len(got))
+	isEqual(t, "v1.2.3", got[0].Version)
+	isEqual(t, "commit-6", got[1].Commit)
+	if txt, err = gitsemver.VersionsJSON(nil); err != nil || txt != "[]" {
+		t.Errorf("VersionsJSON(nil) = %q, %v", txt, err)
+	}
+}
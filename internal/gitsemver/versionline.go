@@ -0,0 +1,76 @@
+package gitsemver
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// VersionLine is a line of maintenance releases, like 1.2.x
+// or 1.x.y, as given by the name of a release branch.
+type VersionLine struct {
+	Major int
+	Minor int // -1 if the line includes all minor versions
+}
+
+var reVersionLine = regexp.MustCompile(`(?:^|[-_])(v?)(\d+)(?:\.(\d+))?(\.x)?$`)
+
+// ParseVersionLine returns the version line at the end of a branch
+// name, like "release/1.2", "release-v2" or "1.2.x". It returns nil
+// if the branch name doesn't end with a version. A bare number only
+// counts with a "v" prefix, so "hotfix/issue-42" has no line.
+func ParseVersionLine(branchName string) *VersionLine {
+	if m := reVersionLine.FindStringSubmatch(LastName(branchName)); m != nil && (m[1] != "" || m[3] != "" || m[4] != "") {
+		return newVersionLine(m[2], m[3])
+	}
+	return nil
+}
+
+func newVersionLine(major, minor string) (line *VersionLine) {
+	if n, err := strconv.Atoi(major); err == nil {
+		line = &VersionLine{Major: n, Minor: -1}
+		if minor != "" {
+			if line.Minor, err = strconv.Atoi(minor); err != nil {
+				line = nil
+			}
+		}
+	}
+	return
+}
+
+func (line *VersionLine) String() string {
+	s := strconv.Itoa(line.Major)
+	if line.Minor >= 0 {
+		s += "." + strconv.Itoa(line.Minor)
+	}
+	return s
+}
+
+// Compare returns 0 if the semver tag is in the line, -1 if it's
+// below it and +1 if it's above it. Tags that aren't semver are below.
+func (line *VersionLine) Compare(tag string) int {
+	_, core, _, ok := splitSemverTag(tag)
+	switch {
+	case !ok || core[0] < line.Major:
+		return -1
+	case core[0] > line.Major:
+		return 1
+	case line.Minor < 0 || core[1] == line.Minor:
+		return 0
+	case core[1] < line.Minor:
+		return -1
+	}
+	return 1
+}
+
+// Contains returns true if the semver tag is in the line.
+func (line *VersionLine) Contains(tag string) bool {
+	return line.Compare(tag) == 0
+}
+
+// MaxBump returns the largest version increment that stays in the line.
+func (line *VersionLine) MaxBump() Bump {
+	if line.Minor < 0 {
+		return BumpMinor
+	}
+	return BumpPatch
+}
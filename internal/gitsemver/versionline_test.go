@@ -0,0 +1,52 @@
+package gitsemver_test
+
+import (
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_ParseVersionLine(t *testing.T) {
+	for branch, want := range map[string]string{
+		"release/1.2":     "1.2",
+		"release-v2":      "2",
+		"1.2.x":           "1.2",
+		"hotfix/v3.10":    "3.10",
+		"maint/1.x":       "1",
+		"release/v2.x":    "2",
+		"hotfix/issue-42": "",
+		"release/2":       "",
+		"main":            "",
+		"release/1.2.3":   "",
+		"feature/a1.2":    "",
+		"":                "",
+	} {
+		got := ""
+		if line := gitsemver.ParseVersionLine(branch); line != nil {
+			got = line.String()
+		}
+		if got != want {
+			t.Errorf("ParseVersionLine(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+func Test_VersionLine_Compare(t *testing.T) {
+	line := &gitsemver.VersionLine{Major: 1, Minor: 2}
+	isEqual(t, 0, line.Compare("v1.2.3"))
+	isEqual(t, 0, line.Compare("1.2"))
+	isEqual(t, 0, line.Compare("v1.2.4-rc.1"))
+	isEqual(t, -1, line.Compare("v1.1.9"))
+	isEqual(t, -1, line.Compare("v0.9.0"))
+	isEqual(t, -1, line.Compare("bogus"))
+	isEqual(t, 1, line.Compare("v1.3.0"))
+	isEqual(t, 1, line.Compare("v2.0.0"))
+	isTrue(t, line.Contains("v1.2.0"))
+	isEqual(t, gitsemver.BumpPatch, line.MaxBump())
+
+	line = &gitsemver.VersionLine{Major: 1, Minor: -1}
+	isEqual(t, 0, line.Compare("v1.9.0"))
+	isEqual(t, 1, line.Compare("v2.0.0"))
+	isEqual(t, gitsemver.BumpMinor, line.MaxBump())
+	isEqual(t, "1", line.String())
+}
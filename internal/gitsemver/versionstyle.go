@@ -0,0 +1,48 @@
+package gitsemver
+
+import "fmt"
+
+// VersionStyle selects how VersionInfo.Version formats
+// work-in-progress versions.
+type VersionStyle int
+
+const (
+	// StyleDefault appends branch and build to the tag as a prerelease,
+	// e.g. "v1.2.3-main.456". This sorts below the tag itself.
+	StyleDefault VersionStyle = iota
+	// StyleNextPatch bases the prerelease on the next patch level,
+	// e.g. "v1.2.4-0.main.456", similar to Go pseudo-versions.
+	// This sorts above the tag it was built after.
+	StyleNextPatch
+	// StyleMetadata puts branch and build in the SemVer build metadata,
+	// e.g. "v1.2.3+main.456". Consumers that ignore build metadata treat
+	// this as the tag itself.
+	StyleMetadata
+)
+
+var versionStyleNames = []string{
+	StyleDefault:   "default",
+	StyleNextPatch: "nextpatch",
+	StyleMetadata:  "metadata",
+}
+
+func (style VersionStyle) String() string {
+	if style >= 0 && int(style) < len(versionStyleNames) {
+		return versionStyleNames[style]
+	}
+	return fmt.Sprintf("VersionStyle(%d)", int(style))
+}
+
+// ParseVersionStyle returns the VersionStyle with the given name.
+// An empty name selects StyleDefault.
+func ParseVersionStyle(name string) (style VersionStyle, err error) {
+	if name != "" {
+		for i, s := range versionStyleNames {
+			if s == name {
+				return VersionStyle(i), nil
+			}
+		}
+		err = fmt.Errorf("unknown version style %q", name)
+	}
+	return
+}
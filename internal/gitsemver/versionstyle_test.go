@@ -0,0 +1,82 @@
+package gitsemver_test
+
+import (
+	"testing"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_ParseVersionStyle(t *testing.T) {
+	for _, style := range []gitsemver.VersionStyle{gitsemver.StyleDefault, gitsemver.StyleNextPatch, gitsemver.StyleMetadata} {
+		got, err := gitsemver.ParseVersionStyle(style.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		isEqual(t, style, got)
+	}
+	got, err := gitsemver.ParseVersionStyle("")
+	isEqual(t, err, nil)
+	isEqual(t, gitsemver.StyleDefault, got)
+	if _, err = gitsemver.ParseVersionStyle("bogus"); err == nil {
+		t.Fatal("expected error for unknown style")
+	}
+	isEqual(t, "VersionStyle(99)", gitsemver.VersionStyle(99).String())
+}
+
+func Test_VersionInfo_Version_StyleNextPatch(t *testing.T) {
+	tests := []struct {
+		tag    string
+		branch string
+		build  string
+		want   string
+	}{
+		{tag: "v1.2.3", branch: "main", build: "456", want: "v1.2.4-0.main.456"},
+		{tag: "v1.2", branch: "feature/x", build: "7", want: "v1.2.1-0.feature-x.7"},
+		{tag: "1", branch: "", build: "7", want: "1.0.1-0.7"},
+		{tag: "v1.2.3", branch: "", build: "", want: "v1.2.4-0"},
+		{tag: "v1.3.0-rc.1", branch: "main", build: "456", want: "v1.3.0-rc.1.0.main.456"},
+		{tag: "not-semver", branch: "main", build: "456", want: "not-semver-main.456"},
+	}
+	for _, tt := range tests {
+		vi := &gitsemver.VersionInfo{Tag: tt.tag, Branch: tt.branch, Build: tt.build, Style: gitsemver.StyleNextPatch}
+		if got := vi.Version(); got != tt.want {
+			t.Errorf("Version(%q): expected %q, got %q", tt.tag, tt.want, got)
+		}
+	}
+	vi := &gitsemver.VersionInfo{Tag: "v1.2.3", Branch: "main", Build: "456", SameTree: true, IsRelease: true, Style: gitsemver.StyleNextPatch}
+	isEqual(t, "v1.2.3", vi.Version())
+}
+
+func Test_VersionStringer_GetVersion_Style(t *testing.T) {
+	git := &MockGitter{}
+	vs := gitsemver.GitSemVer{Git: git, Env: MockEnvironment{}, Style: gitsemver.StyleNextPatch}
+	vi, err := vs.GetVersion(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "v6.0.1-0.main.build", vi.Version())
+}
+
+func Test_VersionInfo_Version_StyleMetadata(t *testing.T) {
+	tests := []struct {
+		tag    string
+		branch string
+		build  string
+		want   string
+		safe   string
+	}{
+		{tag: "v1.2.3", branch: "main", build: "456", want: "v1.2.3+main.456", safe: "v1.2.3_main.456"},
+		{tag: "v1.2.3", branch: "Feature/X_y", build: "7", want: "v1.2.3+feature-x-y.7", safe: "v1.2.3_feature-x-y.7"},
+		{tag: "v1.3.0-rc.1", branch: "main", build: "", want: "v1.3.0-rc.1+main", safe: "v1.3.0-rc.1_main"},
+		{tag: "v1.2.3", branch: "", build: "", want: "v1.2.3", safe: "v1.2.3"},
+	}
+	for _, tt := range tests {
+		vi := &gitsemver.VersionInfo{Tag: tt.tag, Branch: tt.branch, Build: tt.build, Style: gitsemver.StyleMetadata}
+		if got := vi.Version(); got != tt.want {
+			t.Errorf("Version(%q): expected %q, got %q", tt.tag, tt.want, got)
+		}
+		if got := vi.SafeVersion(); got != tt.safe {
+			t.Errorf("SafeVersion(%q): expected %q, got %q", tt.tag, tt.safe, got)
+		}
+	}
+}
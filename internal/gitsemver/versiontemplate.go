@@ -0,0 +1,64 @@
+package gitsemver
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const shortCommitLength = 7
+
+func semverComponent(tag string, idx int) (n int, err error) {
+	var core [3]int
+	var ok bool
+	if _, core, _, ok = splitSemverTag(tag); ok {
+		return core[idx], nil
+	}
+	return 0, fmt.Errorf("%q is not a semver tag", tag)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > shortCommitLength {
+		commit = commit[:shortCommitLength]
+	}
+	return commit
+}
+
+func commitDate(when time.Time, layout ...string) string {
+	l := time.RFC3339
+	if len(layout) > 0 {
+		l = layout[0]
+	}
+	return when.UTC().Format(l)
+}
+
+var templateFuncs = template.FuncMap{
+	"major":       func(tag string) (int, error) { return semverComponent(tag, 0) },
+	"minor":       func(tag string) (int, error) { return semverComponent(tag, 1) },
+	"patch":       func(tag string) (int, error) { return semverComponent(tag, 2) },
+	"cleanBranch": CleanBranch,
+	"shortCommit": shortCommit,
+	"commitDate":  commitDate,
+}
+
+// Template renders the text/template source text with the VersionInfo as data.
+// The name is used in error messages. Besides the VersionInfo fields and
+// methods (e.g. {{.Version}}), templates may use these functions:
+//
+//	major TAG                 MAJOR component of a semver tag, e.g. {{major .Tag}}
+//	minor TAG                 MINOR component of a semver tag
+//	patch TAG                 PATCH component of a semver tag
+//	cleanBranch BRANCH        branch as used in the version, e.g. {{cleanBranch .Branch}}
+//	shortCommit HASH          abbreviated commit hash, e.g. {{shortCommit .Commit}}
+//	commitDate TIME [LAYOUT]  UTC time as RFC 3339 or the given layout, e.g. {{commitDate .CommitTime "2006-01-02"}}
+func (vi *VersionInfo) Template(name, text string) (retv string, err error) {
+	var tmpl *template.Template
+	if tmpl, err = template.New(name).Funcs(templateFuncs).Parse(text); err == nil {
+		var sb strings.Builder
+		if err = tmpl.Execute(&sb, vi); err == nil {
+			retv = sb.String()
+		}
+	}
+	return
+}
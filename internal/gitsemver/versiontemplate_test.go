@@ -0,0 +1,42 @@
+package gitsemver_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
+)
+
+func Test_VersionInfo_Template(t *testing.T) {
+	vi := &gitsemver.VersionInfo{
+		Tag:        "v1.2",
+		Branch:     "My/Branch",
+		Build:      "456",
+		Commit:     "0123456789abcdef",
+		CommitTime: time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("X", 3600)),
+	}
+	txt, err := vi.Template("test", `{{major .Tag}}.{{minor .Tag}}.{{patch .Tag}} {{cleanBranch .Branch}} {{.Version}} {{shortCommit .Commit}} {{commitDate .CommitTime}} {{commitDate .CommitTime "20060102"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isEqual(t, "1.2.0 my-branch v1.2-my-branch.456 0123456 2024-05-06T06:08:09Z 20240506", txt)
+
+	vi.Commit = "abc"
+	txt, err = vi.Template("test", `{{shortCommit .Commit}}`)
+	isEqual(t, nil, err)
+	isEqual(t, "abc", txt)
+}
+
+func Test_VersionInfo_Template_Errors(t *testing.T) {
+	vi := &gitsemver.VersionInfo{Tag: "release"}
+	if _, err := vi.Template("test", `{{major .Tag}}`); err == nil || !strings.Contains(err.Error(), "not a semver tag") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := vi.Template("test", `{{.NoSuchField}}`); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := vi.Template("test", `{{`); err == nil {
+		t.Error("expected parse error")
+	}
+}
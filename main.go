@@ -9,6 +9,7 @@ import (
 	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 
@@ -37,6 +38,47 @@ func replaceFile(source, target string) (err error) {
 	return
 }
 
+// checkOutputPath cleans fileName and checks that it is either missing or not a directory.
+func checkOutputPath(fileName string) (cleaned string, err error) {
+	cleaned = filepath.Clean(fileName)
+	if fi, statErr := os.Stat(cleaned); statErr == nil { // #nosec G703
+		if fi.IsDir() {
+			err = fmt.Errorf("%q is a directory", cleaned)
+		}
+	} else if !errors.Is(statErr, fs.ErrNotExist) {
+		err = statErr
+	}
+	return
+}
+
+// checkOutput returns a unified diff between the current contents of fileName and content,
+// or an empty string if they are equal. A missing file is treated as empty.
+func checkOutput(fileName, content string) (diff string, err error) {
+	if fileName, err = checkOutputPath(fileName); err == nil {
+		var b []byte
+		if b, err = os.ReadFile(fileName); err == nil || errors.Is(err, fs.ErrNotExist) /* #nosec G304 */ {
+			err = nil
+			diff = unifiedDiff(fileName, fileName+" (generated)", string(b), content)
+		}
+	}
+	return
+}
+
+// runCheck implements -check, returning 1 and printing a diff if fileName is out of date.
+func runCheck(fileName, content string) int {
+	diff, err := checkOutput(fileName, content)
+	if err == nil {
+		if diff == "" {
+			return 0
+		}
+		_, _ = os.Stdout.WriteString(diff)
+		fmt.Fprintf(os.Stderr, "%q is out of date\n", fileName) // #nosec G705
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, err.Error()) // #nosec G705
+	return exitCodeForError(err)
+}
+
 func prepareOutput(fileName, content string) (publish func() error, cleanup func(), err error) {
 	cleanup = func() {}
 	publish = func() error {
@@ -44,14 +86,7 @@ func prepareOutput(fileName, content string) (publish func() error, cleanup func
 		return err
 	}
 	if fileName != "" {
-		fileName = filepath.Clean(fileName)
-		if fi, statErr := os.Stat(fileName); statErr == nil { // #nosec G703
-			if fi.IsDir() {
-				err = fmt.Errorf("%q is a directory", fileName)
-				return
-			}
-		} else if !errors.Is(statErr, fs.ErrNotExist) {
-			err = statErr
+		if fileName, err = checkOutputPath(fileName); err != nil {
 			return
 		}
 		// File output is always staged: write to temp first, then publish by replace.
@@ -74,19 +109,58 @@ func prepareOutput(fileName, content string) (publish func() error, cleanup func
 	return
 }
 
+// stringList is the value of a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// listFlag defines a flag that collects each value it is given.
+func listFlag(name, usage string) *stringList {
+	l := &stringList{}
+	flag.Var(l, name, usage)
+	return l
+}
+
 var (
-	flagGit       = flag.String("git", "git", "path to Git executable")
-	flagOut       = flag.String("out", "", "write to file instead of stdout (relative paths are relative to repo)")
-	flagName      = flag.String("name", "", "set the PkgName used in gopackage, default is to use last portion of module in go.mod")
-	flagPackage   = flag.String("package", "", "override the go package used in gopackage, default is to use last portion of module in go.mod")
-	flagDebug     = flag.Bool("debug", false, "write debug info to stderr")
-	flagGoPackage = flag.Bool("gopackage", false, "write Go source with PkgName and PkgVersion")
-	flagNoFetch   = flag.Bool("nofetch", false, "don't fetch remote tags")
-	flagNoNewline = flag.Bool("nonewline", false, "don't print a newline after the output")
-	flagIncPatch  = flag.Bool("incpatch", false, "increment the patch level and create a new tag")
-	flagIncMinor  = flag.Bool("incminor", false, "increment the minor level and create a new tag")
-	flagBranch    = flag.Bool("branch", false, "print the current branch name")
-	flagVersion   = flag.Bool("version", false, "print the version of gitsemver and exit")
+	flagGit        = flag.String("git", "git", "path to Git executable, or \"internal\" to read the repository without it")
+	flagOut        = flag.String("out", "", "write to file instead of stdout (relative paths are relative to repo)")
+	flagName       = flag.String("name", "", "set the PkgName used in gopackage, default is to use last portion of module in go.mod")
+	flagPackage    = flag.String("package", "", "override the go package used in gopackage, default is to use last portion of module in go.mod")
+	flagDebug      = flag.Bool("debug", false, "write debug info to stderr")
+	flagGoPackage  = flag.Bool("gopackage", false, "write Go source with PkgName and PkgVersion")
+	flagGoFields   = flag.String("gofields", "", "comma-separated extra constants for -gopackage: commit, tree, branch, build, committime, dirty or all")
+	flagNoFetch    = flag.Bool("nofetch", false, "don't fetch remote tags")
+	flagNoNewline  = flag.Bool("nonewline", false, "don't print a newline after the output")
+	flagIncPatch   = flag.Bool("incpatch", false, "increment the patch level and create a new tag")
+	flagIncMinor   = flag.Bool("incminor", false, "increment the minor level and create a new tag")
+	flagIncMajor   = flag.Bool("incmajor", false, "increment the major level and create a new tag")
+	flagIncAuto    = flag.Bool("incauto", false, "increment the level implied by Conventional Commits since the last tag and create a new tag")
+	flagPrerelease = flag.String("prerelease", "", "create a prerelease tag on the given channel (e.g. rc, beta or alpha)")
+	flagPromote    = flag.Bool("promote", false, "create the release tag for the prerelease tag at HEAD")
+	flagStyle      = flag.String("style", "", "version style for work-in-progress versions: default, nextpatch or metadata")
+	flagBuildMode  = flag.String("build-mode", "", "build number: all (CI counter or all commits), path (commits touching -module-dir) or height (commits since the tag)")
+	flagSafe       = flag.Bool("safe", false, "replace '+' in the version with '_' (e.g. for OCI image tags)")
+	flagFormat     = flag.String("format", "text", "output format: text or json")
+	flagTemplate   = flag.String("template", "", "render the given text/template file with the version info (relative paths are relative to repo)")
+	flagBranch     = flag.Bool("branch", false, "print the current branch name")
+	flagRelease    = listFlag("release-branches", "release branch, a glob or a regular expression starting with ^, may be given more than once, default is \"default\", \"master\", \"main\" or $CI_DEFAULT_BRANCH")
+	flagPrefix     = flag.String("prefix", "", "only use tags starting with this prefix, e.g. \"tools/cli/\"")
+	flagModuleDir  = flag.String("module-dir", "", "directory of the Go module to version, sets the tag prefix if -prefix isn't given (relative paths are relative to repo, default is the nearest go.mod above the given directory whose prefix has tags)")
+	flagFullTag    = flag.Bool("fulltag", false, "include the tag prefix in the printed version")
+	flagCIOutput   = flag.Bool("ci-output", false, "also write the version to GitHub Actions outputs and environment or a GitLab dotenv report")
+	flagCheck      = flag.Bool("check", false, "don't write anything, exit with status 1 and print a diff if the -out file is out of date")
+	flagRemote     = listFlag("remote", "remote to push tags to, may be given more than once, default is \"origin\"")
+	flagTagSource  = flag.String("tag-source-remote", "", "remote to fetch tags from, default is the first -remote or the default remote")
+	flagNoConfig   = flag.Bool("noconfig", false, "don't read the repository config file")
+	flagCatFile    = flag.Bool("catfile", false, "keep one git cat-file process running for object queries instead of starting git for each, no effect with -git internal")
+	flagVersion    = flag.Bool("version", false, "print the version of gitsemver and exit")
 )
 
 var exitFn func(int) = os.Exit
@@ -101,98 +175,586 @@ func exitCodeForError(err error) int {
 	return retv
 }
 
-func mainfn() int {
-	repoDir := os.ExpandEnv(flag.Arg(0))
-	if repoDir == "" {
-		repoDir = "."
-	}
+// moduleDir returns the directory of the Go module to version, as set by setModule.
+func moduleDir(vs *gitsemver.GitSemVer, repoDir string) string {
+	return filepath.Join(repoDir, filepath.FromSlash(vs.TreePath))
+}
 
-	var debugOut io.Writer
-	if *flagDebug {
-		debugOut = os.Stderr
+// findModuleDir returns the directory of the go.mod nearest to dir, looking
+// upwards no further than the repository root. Returns an empty string if
+// there is no go.mod, or if the nearest one is in the repository root.
+func findModuleDir(repoDir, dir string) (modDir string, err error) {
+	if dir, err = filepath.Abs(dir); err == nil {
+		for strings.HasPrefix(dir, repoDir+string(filepath.Separator)) {
+			if _, e := os.Stat(filepath.Join(dir, "go.mod")); e == nil {
+				return dir, nil
+			}
+			dir = filepath.Dir(dir)
+		}
 	}
+	return
+}
 
-	if *flagVersion {
-		fmt.Println(gitsemver.PkgName, gitsemver.PkgVersion)
-		return 0
+// setModule sets the tag prefix from -prefix, or to the one the Go toolchain expects for
+// the module, and restricts tree hash comparison to the module directory. The module is
+// the one in -module-dir, or if not given, the one of the nearest go.mod above targetDir
+// that isn't in the repository root, if any, and whose prefix some tags have.
+func setModule(vs *gitsemver.GitSemVer, repoDir, targetDir string) (err error) {
+	modDir := repoPath(repoDir, *flagModuleDir)
+	if modDir == "" {
+		if modDir, err = findModuleDir(repoDir, targetDir); err == nil && modDir != "" && *flagPrefix == "" {
+			var prefix string
+			if prefix, err = gitsemver.ModuleTagPrefix(repoDir, modDir); err == nil {
+				var tags []string
+				if tags, err = vs.Git.GetTags(repoDir, prefix); err == nil && len(tags) == 0 {
+					// the repository is versioned as a whole until the module gets tagged
+					vs.Debug("no tags with prefix %q, ignoring the module in %s\n", prefix, modDir)
+					modDir = ""
+				}
+			}
+		}
 	}
+	if vs.TagPrefix = *flagPrefix; err == nil && modDir != "" {
+		if vs.TagPrefix == "" {
+			vs.TagPrefix, err = gitsemver.ModuleTagPrefix(repoDir, modDir)
+		}
+		if err == nil {
+			var rel string
+			if rel, err = filepath.Rel(repoDir, modDir); err == nil && rel != "." {
+				vs.TreePath = filepath.ToSlash(rel)
+			}
+		}
+	}
+	if err == nil && vs.BuildMode == gitsemver.BuildPath && vs.TreePath == "" {
+		err = errors.New("-build-mode path requires a module below the repository root")
+	}
+	return
+}
 
-	vs, err := gitsemver.New(*flagGit, debugOut)
-	if err == nil {
-		var createTag string
-		if repoDir, err = vs.Git.CheckGitRepo(repoDir); err == nil {
-			if !*flagNoFetch {
-				err = vs.Git.FetchTags(repoDir)
-			}
-			if err == nil {
-				var vi gitsemver.VersionInfo
-				if vi, err = vs.GetVersion(repoDir); err == nil {
-					if *flagIncPatch && *flagIncMinor {
-						err = errors.New("cannot use both -incpatch and -incminor")
+// bumpVersion increments vi according to the -inc, -prerelease and -promote
+// flags and returns the tag to create, or an empty string if no tag should be created.
+func bumpVersion(vs *gitsemver.GitSemVer, repoDir string, vi *gitsemver.VersionInfo) (createTag string, err error) {
+	bumps := 0
+	for _, inc := range []bool{*flagIncPatch, *flagIncMinor, *flagIncMajor, *flagIncAuto, *flagPromote} {
+		if inc {
+			bumps++
+		}
+	}
+	if bumps > 1 {
+		err = errors.New("only one of -incpatch, -incminor, -incmajor, -incauto and -promote can be used")
+	} else if *flagPromote && *flagPrerelease != "" {
+		err = errors.New("cannot use both -promote and -prerelease")
+	} else if *flagPrerelease != "" && !gitsemver.IsPrereleaseChannel(*flagPrerelease) {
+		err = fmt.Errorf("%q is not a valid prerelease channel", *flagPrerelease)
+	} else if bumps > 0 || *flagPrerelease != "" {
+		var clean bool
+		if clean, err = vs.Git.CleanStatus(repoDir, true); err == nil {
+			if clean {
+				bump := gitsemver.BumpNone
+				switch {
+				case *flagIncPatch:
+					bump = gitsemver.BumpPatch
+				case *flagIncMinor:
+					bump = gitsemver.BumpMinor
+				case *flagIncMajor:
+					bump = gitsemver.BumpMajor
+				case *flagIncAuto:
+					if bump, err = vs.GetBump(repoDir, vi.FullTag()); err == nil && bump == gitsemver.BumpNone {
+						err = fmt.Errorf("nothing to release since %s", vi.Tag)
 					}
-					if err == nil && (*flagIncPatch || *flagIncMinor) {
-						var clean bool
-						if clean, err = vs.Git.CleanStatus(repoDir, true); err == nil {
-							if clean {
-								if *flagIncPatch {
-									createTag = vi.IncPatch()
-								}
-								if *flagIncMinor {
-									createTag = vi.IncMinor()
-								}
-								if testMode {
-									createTag = ""
-								}
-							} else {
-								err = errors.New("cannot bump version with uncommitted changes")
-							}
+					if err == nil && vi.Line != nil && vi.Line.Contains(vi.Tag) && bump > vi.Line.MaxBump() {
+						err = fmt.Errorf("%s change cannot be released in the %s line of branch %q", bump, vi.Line, vi.Branch)
+					}
+				}
+				if err == nil {
+					switch {
+					case *flagPromote:
+						if !vi.SameTree || !vi.IsPrerelease() {
+							err = errors.New("-promote requires HEAD to be at a prerelease tag")
+						} else if createTag = vi.Promote(); createTag == "" {
+							err = fmt.Errorf("cannot promote %s, the release tag already exists", vi.Tag)
 						}
+					case *flagPrerelease != "":
+						createTag = vi.IncPrerelease(bump, *flagPrerelease)
+					default:
+						createTag = vi.Inc(bump)
 					}
-					content := vi.Version()
-					if *flagBranch {
-						content = vi.Branch
+				}
+				if err == nil && createTag != "" && vi.Line != nil && !vi.Line.Contains(createTag) {
+					err = fmt.Errorf("%s is outside the %s line of branch %q", createTag, vi.Line, vi.Branch)
+				}
+				if err == nil {
+					// The Go toolchain rejects tags that don't match the module path.
+					if err = gitsemver.CheckModuleMajor(moduleDir(vs, repoDir), createTag); err == nil && createTag != "" {
+						createTag = vi.TagPrefix + createTag
 					}
-					if *flagGoPackage {
-						content, err = vi.GoPackage(repoDir, *flagName, *flagPackage, createTag)
+				}
+				if createTag != "" && *flagOut != "" {
+					// the tag goes on the version commit, which doesn't exist yet
+					vi.TagCommit = ""
+				}
+				if testMode {
+					createTag = ""
+				}
+			} else {
+				err = errors.New("cannot bump version with uncommitted changes")
+			}
+		}
+	}
+	return
+}
+
+// configFlags are the flags that may be set in the repository config file.
+var configFlags = []string{
+	"build-mode", "catfile", "ci-output", "format", "fulltag", "gofields", "gopackage", "module-dir", "name",
+	"nofetch", "nonewline", "out", "package", "prefix", "release-branches", "remote", "safe", "style",
+	"tag-source-remote", "template",
+}
+
+// applyConfig reads the repository config file and sets the flags not given on the
+// command line and the Conventional Commits bump rules.
+func applyConfig(vs *gitsemver.GitSemVer, repoDir string) (err error) {
+	var cfg *gitsemver.Config
+	if cfg, err = gitsemver.LoadConfig(repoDir); err == nil && cfg != nil {
+		given := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		for _, key := range cfg.Keys {
+			values := cfg.Values[key]
+			switch {
+			case strings.HasPrefix(key, "bump-"):
+				var bump gitsemver.Bump
+				switch key {
+				case "bump-major":
+					bump = gitsemver.BumpMajor
+				case "bump-minor":
+					bump = gitsemver.BumpMinor
+				case "bump-patch":
+					bump = gitsemver.BumpPatch
+				default:
+					return fmt.Errorf("%s: unknown key %q", cfg.File, key)
+				}
+				if vs.BumpRules == nil {
+					vs.BumpRules = gitsemver.BumpRules{}
+				}
+				for _, commitType := range values {
+					vs.BumpRules[strings.ToLower(commitType)] = bump
+				}
+			case slices.Contains(configFlags, key):
+				f := flag.Lookup(key)
+				if _, isList := f.Value.(*stringList); !isList {
+					value, ok := cfg.Get(key)
+					if !ok {
+						return fmt.Errorf("%s: %q must be a single value", cfg.File, key)
 					}
-					if err == nil {
-						outpath := os.ExpandEnv(*flagOut)
-						if outpath != "" && !filepath.IsAbs(outpath) {
-							outpath = filepath.Join(repoDir, outpath)
+					values = []string{value}
+				}
+				if !given[key] {
+					for _, value := range values {
+						if err = f.Value.Set(value); err != nil {
+							return fmt.Errorf("%s: %q: %w", cfg.File, key, err)
 						}
-						if !*flagNoNewline && !strings.HasSuffix(content, "\n") {
-							content += "\n"
+					}
+				}
+			default:
+				return fmt.Errorf("%s: unknown key %q", cfg.File, key)
+			}
+		}
+	}
+	return
+}
+
+// writesRepo returns true if flags that create tags or write CI output are given.
+func writesRepo() bool {
+	return *flagIncPatch || *flagIncMinor || *flagIncMajor || *flagIncAuto || *flagPromote || *flagPrerelease != "" || *flagCIOutput
+}
+
+// checkFlags validates -format and rejects conflicting flags.
+func checkFlags() (err error) {
+	switch *flagFormat {
+	case "text":
+		if *flagTemplate != "" && (*flagGoPackage || *flagBranch) {
+			err = errors.New("cannot use -template with -gopackage or -branch")
+		}
+	case "json":
+		if *flagGoPackage || *flagBranch || *flagTemplate != "" {
+			err = errors.New("cannot use -format json with -gopackage, -branch or -template")
+		}
+	default:
+		err = fmt.Errorf("unknown output format %q", *flagFormat)
+	}
+	if err == nil && *flagGoFields != "" && !*flagGoPackage {
+		err = errors.New("-gofields requires -gopackage")
+	}
+	if err == nil && *flagCheck {
+		if *flagOut == "" {
+			err = errors.New("-check requires -out")
+		} else if writesRepo() {
+			err = errors.New("-check cannot be used with flags that create tags or write CI output")
+		}
+	}
+	return
+}
+
+// repoPath expands environment variables in fileName and makes relative paths relative to repoDir.
+func repoPath(repoDir, fileName string) (s string) {
+	if s = os.ExpandEnv(fileName); s != "" && !filepath.IsAbs(s) {
+		s = filepath.Join(repoDir, s)
+	}
+	return
+}
+
+func renderTemplate(vi *gitsemver.VersionInfo, fileName string) (content string, err error) {
+	var b []byte
+	if b, err = os.ReadFile(fileName); err == nil /* #nosec G304 */ {
+		content, err = vi.Template(filepath.Base(fileName), string(b))
+	}
+	return
+}
+
+// commands are the commands that may come before the repository directory,
+// and what they require as argument.
+var commands = map[string]string{
+	"describe": "a revision",
+	"log":      "a revision range",
+	"resolve":  "a version",
+}
+
+// parseArgs returns the command and its argument if the arguments start with
+// one, and the repository directory, which defaults to ".".
+func parseArgs(args []string) (command, commandArg, repoDir string, err error) {
+	if len(args) > 0 && commands[args[0]] != "" {
+		if len(args) < 2 || args[1] == "" {
+			return "", "", "", fmt.Errorf("%s requires %s", args[0], commands[args[0]])
+		}
+		command, commandArg, args = args[0], args[1], args[2:]
+	}
+	if len(args) > 0 {
+		repoDir = os.ExpandEnv(args[0])
+	}
+	if repoDir == "" {
+		repoDir = "."
+	}
+	return
+}
+
+// versionText returns the version of vi as printed, according to -safe and -fulltag.
+func versionText(vi *gitsemver.VersionInfo) (s string) {
+	s = vi.Version()
+	if *flagSafe {
+		s = vi.SafeVersion()
+	}
+	if *flagFullTag {
+		s = vi.TagPrefix + s
+	}
+	return
+}
+
+// runOutput writes the output of a command the same way the version is written,
+// according to -out, -check and -nonewline, and returns the exit code. If err
+// is not nil, it is printed instead.
+func runOutput(repoDir, content string, err error) int {
+	if err == nil {
+		outpath := repoPath(repoDir, *flagOut)
+		if *flagNoNewline {
+			content = strings.TrimSuffix(content, "\n")
+		} else if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if *flagCheck {
+			return runCheck(outpath, content)
+		}
+		var publish func() error
+		var cleanup func()
+		if publish, cleanup, err = prepareOutput(outpath, content); err == nil {
+			defer cleanup()
+			if err = publish(); err == nil {
+				return 0
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, err.Error()) // #nosec G705
+	return exitCodeForError(err)
+}
+
+// runLog implements the log command, printing each commit in revRange with its
+// version, or a JSON array of their VersionJSON with -format json.
+func runLog(vs *gitsemver.GitSemVer, repoDir, revRange string) int {
+	versions, err := vs.GetVersions(repoDir, revRange)
+	var content string
+	if err == nil {
+		if *flagFormat == "json" {
+			content, err = gitsemver.VersionsJSON(versions)
+		} else {
+			var sb strings.Builder
+			for i := range versions {
+				fmt.Fprintf(&sb, "%s %s\n", versions[i].Commit, versionText(&versions[i]))
+			}
+			content = sb.String()
+		}
+	}
+	return runOutput(repoDir, content, err)
+}
+
+// runResolve implements the resolve command, printing the commit that has the version.
+func runResolve(vs *gitsemver.GitSemVer, repoDir, version string) int {
+	matches, err := vs.ResolveVersion(repoDir, version)
+	var content string
+	if err == nil {
+		if len(matches) == 0 {
+			err = fmt.Errorf("no commit has version %q", version)
+		} else {
+			content = matches[0].Commit
+		}
+	}
+	return runOutput(repoDir, content, err)
+}
+
+// writeCIOutput writes the version to the CI system if -ci-output is given.
+func writeCIOutput(vs *gitsemver.GitSemVer, repoDir string, vi *gitsemver.VersionInfo) (err error) {
+	if *flagCIOutput {
+		err = vs.WriteCIOutput(repoDir, vi, versionText(vi))
+	}
+	return
+}
+
+// maxPushRetries is how many times a rejected push is retried with the next
+// free version, as when another job pushed the same tag first.
+const maxPushRetries = 3
+
+// runVersion computes the version, writes it and creates and pushes the new tag,
+// if any. A commit or tag made here is rolled back if the push fails. The
+// returned code and version are only meaningful if err is nil.
+func runVersion(vs *gitsemver.GitSemVer, command, commandArg, repoDir string, goFields gitsemver.GoFields) (code int, vi gitsemver.VersionInfo, err error) {
+	if command == "describe" {
+		vi, err = vs.GetVersionAt(repoDir, commandArg)
+	} else {
+		vi, err = vs.GetVersion(repoDir)
+	}
+	if err == nil {
+		var createTag string
+		createTag, err = bumpVersion(vs, repoDir, &vi)
+		content := versionText(&vi)
+		if *flagBranch {
+			content = vi.Branch
+		}
+		if err == nil && *flagGoPackage {
+			content, err = vi.GoPackageFields(moduleDir(vs, repoDir), *flagName, *flagPackage, strings.TrimPrefix(createTag, vi.TagPrefix), goFields)
+		}
+		if err == nil && *flagFormat == "json" {
+			content, err = vi.JSON()
+		}
+		if err == nil && *flagTemplate != "" {
+			content, err = renderTemplate(&vi, repoPath(repoDir, *flagTemplate))
+		}
+		if err == nil && *flagCIOutput {
+			// fail before tagging, the output itself is written by mainfn once the push succeeded
+			err = vs.CheckCIOutput()
+		}
+		if err == nil {
+			outpath := repoPath(repoDir, *flagOut)
+			if !*flagNoNewline && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			if *flagCheck {
+				return runCheck(outpath, content), vi, nil
+			}
+			var publish func() error
+			var cleanup func()
+			if publish, cleanup, err = prepareOutput(outpath, content); err == nil {
+				defer cleanup()
+				var preRunHead, afterHead string
+				if preRunHead, err = vs.Git.GetHead(repoDir, createTag == ""); err == nil {
+					if err = publish(); err == nil {
+						commitPath := ""
+						if createTag != "" {
+							commitPath = outpath
 						}
-						var publish func() error
-						var cleanup func()
-						if publish, cleanup, err = prepareOutput(outpath, content); err == nil {
-							defer cleanup()
-							var preRunHead, afterHead string
-							if preRunHead, err = vs.Git.GetHead(repoDir, createTag == ""); err == nil {
-								if err = publish(); err == nil {
-									commitPath := ""
-									if createTag != "" {
-										commitPath = outpath
+						if err = vs.Git.Commit(repoDir, commitPath, createTag); err == nil {
+							if afterHead, err = vs.Git.GetHead(repoDir, createTag == ""); err == nil {
+								var pushBranch string
+								if preRunHead != afterHead {
+									// the version commit must reach a branch along with the tag
+									if pushBranch, err = vs.GetPushBranch(repoDir); err == nil && pushBranch == "" {
+										err = errors.New("cannot push the version commit from a detached HEAD")
 									}
-									if err = vs.Git.Commit(repoDir, commitPath, createTag); err == nil {
-										if afterHead, err = vs.Git.GetHead(repoDir, createTag == ""); err == nil {
-											if err = vs.Git.CreateTag(repoDir, createTag); err == nil {
-												if err = vs.Git.PushTag(repoDir, createTag); err == nil {
-													return 0
-												}
-												// remove the tag
-												err = errors.Join(err, vs.Git.DeleteTag(repoDir, createTag))
-												err = errors.Join(err, vs.Git.DeleteRemoteTag(repoDir, createTag))
-											}
+								}
+								if err == nil {
+									if err = vs.Git.CreateTag(repoDir, createTag); err == nil {
+										// the push is atomic, so on failure neither ref changed on the remote
+										if err = pushTag(vs, repoDir, createTag, pushBranch, preRunHead); err == nil {
+											return 0, vi, nil
 										}
-									}
-									// revert the commit
-									if outpath != "" && preRunHead != afterHead {
-										err = errors.Join(err, vs.Git.ResetHard(repoDir, preRunHead))
+										// remove the tag
+										err = errors.Join(err, vs.Git.DeleteTag(repoDir, createTag))
 									}
 								}
 							}
 						}
+						// revert the commit
+						if outpath != "" && preRunHead != afterHead {
+							err = errors.Join(err, vs.Git.ResetHard(repoDir, preRunHead))
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// pushRemotes returns the -remote flags, or the default remote if none are given.
+func pushRemotes() []string {
+	if len(*flagRemote) == 0 {
+		return []string{""}
+	}
+	return *flagRemote
+}
+
+// tagSource returns the remote to fetch tags from, which is -tag-source-remote
+// or the first -remote. Empty means the default remote.
+func tagSource() string {
+	if *flagTagSource == "" && len(*flagRemote) > 0 {
+		return (*flagRemote)[0]
+	}
+	return *flagTagSource
+}
+
+// pushTag pushes the tag, and HEAD to the branch if it isn't empty, to each of
+// the remotes in turn. If a push fails, the remotes already pushed to get the
+// tag removed and the branch moved back to preRunHead, so that either all of
+// them have the new refs or none of them do.
+//
+// Only a rejection by the first remote is returned as ErrPushRejected, since
+// catchUp only catches up with that one, and a retry must not push another
+// tag to remotes that may still have this one.
+func pushTag(vs *gitsemver.GitSemVer, repoDir, tag, branch, preRunHead string) (err error) {
+	remotes := pushRemotes()
+	for i, remote := range remotes {
+		if err = vs.Git.PushTag(repoDir, remote, tag, branch); err != nil {
+			if i > 0 {
+				err = fmt.Errorf("%v", err)
+				for _, pushed := range remotes[:i] {
+					err = errors.Join(err, vs.Git.UnpushTag(repoDir, pushed, tag, branch, preRunHead))
+				}
+			}
+			break
+		}
+	}
+	return
+}
+
+// catchUp fetches the tags, and with -out the branch, that another job pushed
+// since the version was computed, so that it can be computed anew.
+func catchUp(vs *gitsemver.GitSemVer, repoDir string) (err error) {
+	vs.Refresh()
+	if err = vs.Git.FetchTags(repoDir, tagSource()); err == nil && *flagOut != "" {
+		var branch string
+		if branch, err = vs.GetPushBranch(repoDir); err == nil && branch != "" {
+			err = vs.Git.FastForward(repoDir, pushRemotes()[0], branch)
+		}
+	}
+	return
+}
+
+func mainfn() int {
+	command, commandArg, repoDir, err := parseArgs(flag.Args())
+
+	var debugOut io.Writer
+	if *flagDebug {
+		debugOut = os.Stderr
+	}
+
+	if *flagVersion {
+		fmt.Println(gitsemver.PkgName, gitsemver.PkgVersion)
+		return 0
+	}
+
+	targetDir := repoDir
+	var vs *gitsemver.GitSemVer
+	if err == nil {
+		vs, err = gitsemver.New(*flagGit, debugOut)
+	}
+	if err == nil {
+		defer func() {
+			if closer, ok := vs.Git.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}()
+		if repoDir, err = vs.Git.CheckGitRepo(repoDir); err == nil && !*flagNoConfig {
+			err = applyConfig(vs, repoDir)
+		}
+	}
+	if err == nil && *flagCatFile {
+		if dg, ok := vs.Git.(gitsemver.DefaultGitter); ok {
+			dg.CatFile = &gitsemver.CatFile{}
+			vs.Git = dg
+		}
+	}
+	if err == nil {
+		vs.Style, err = gitsemver.ParseVersionStyle(*flagStyle)
+	}
+	if err == nil {
+		for _, pattern := range *flagRelease {
+			if err = gitsemver.CheckBranchPattern(pattern); err != nil {
+				err = fmt.Errorf("release branch pattern %q: %w", pattern, err)
+				break
+			}
+		}
+		vs.ReleaseBranches = *flagRelease
+	}
+	if err == nil {
+		// prerelease tags are only a base to continue or promote
+		vs.Prereleases = *flagPrerelease != "" || *flagPromote
+		vs.BuildMode, err = gitsemver.ParseBuildMode(*flagBuildMode)
+	}
+	if err == nil {
+		err = checkFlags()
+	}
+	if err == nil && command != "" && writesRepo() {
+		err = fmt.Errorf("%s cannot be used with flags that create tags or write CI output", command)
+	}
+	if err == nil && command == "log" && (*flagGoPackage || *flagBranch || *flagTemplate != "") {
+		err = errors.New("log cannot be used with -gopackage, -branch or -template")
+	}
+	if err == nil && command == "resolve" && (*flagGoPackage || *flagBranch || *flagTemplate != "" || *flagFormat != "text") {
+		err = errors.New("resolve cannot be used with -gopackage, -branch, -template or -format json")
+	}
+	var goFields gitsemver.GoFields
+	if err == nil {
+		goFields, err = gitsemver.ParseGoFields(*flagGoFields)
+		// only templates and -gofields committime show the commit time
+		vs.NoCommitTime = *flagTemplate == "" && goFields&gitsemver.GoFieldCommitTime == 0
+	}
+	if err == nil {
+		if !*flagNoFetch {
+			err = vs.Git.FetchTags(repoDir, tagSource())
+		}
+		if err == nil {
+			// the tags must be fetched to tell if the module has any
+			if err = setModule(vs, repoDir, targetDir); err == nil {
+				switch command {
+				case "log":
+					return runLog(vs, repoDir, commandArg)
+				case "resolve":
+					return runResolve(vs, repoDir, commandArg)
+				}
+				for retries := 0; ; retries++ {
+					var code int
+					var vi gitsemver.VersionInfo
+					if code, vi, err = runVersion(vs, command, commandArg, repoDir, goFields); err == nil {
+						// only once, as the GitHub files are appended to
+						if err = writeCIOutput(vs, repoDir, &vi); err == nil {
+							return code
+						}
+						break
+					}
+					if *flagNoFetch || retries == maxPushRetries || !errors.Is(err, gitsemver.ErrPushRejected) {
+						break
+					}
+					// another job pushed first, so catch up and take the next free version
+					vs.Debug("%v, retrying\n", err)
+					if err = catchUp(vs, repoDir); err != nil {
+						break
 					}
 				}
 			}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"go/format"
@@ -9,9 +10,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	gitsemver "github.com/linkdata/gitsemver/internal/gitsemver"
 )
@@ -927,7 +930,7 @@ func TestMainFnIncPatchRollsBackRemoteTagOnPublishError(t *testing.T) {
 	}
 }
 
-func TestMainFnNoFetchDoesNotRunAnyFetch(t *testing.T) {
+func TestMainFnIncPatchPushesCommitAndTagAtomically(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -948,61 +951,53 @@ func TestMainFnNoFetchDoesNotRunAnyFetch(t *testing.T) {
 		testMode = origTestMode
 	}()
 
-	work := t.TempDir()
-	runGit(t, work, "init", "-q")
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "clone", "-q", origin, work)
 	runGit(t, work, "config", "user.email", "test@example.com")
 	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "checkout", "-q", "-B", "main")
 	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
 	runGit(t, work, "add", "a.txt")
 	runGit(t, work, "commit", "-q", "-m", "c1")
 	runGit(t, work, "tag", "v1.0.0")
-	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\nb\n"), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	runGit(t, work, "commit", "-qam", "c2")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
 
 	if err := os.Chdir(work); err != nil {
 		t.Fatal(err)
 	}
 
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
-	*flagDebug = true
+	*flagDebug = false
 	*flagGoPackage = false
 	*flagNoFetch = true
 	*flagNoNewline = false
-	*flagIncPatch = false
+	*flagIncPatch = true
 	*flagIncMinor = false
 	*flagBranch = false
-	testMode = true
+	testMode = false
 
-	origStderr := os.Stderr
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatal(err)
-	}
-	os.Stderr = w
-	code := mainfn()
-	os.Stderr = origStderr
-	_ = w.Close()
-	logBytes, readErr := io.ReadAll(r)
-	_ = r.Close()
-	if readErr != nil {
-		t.Fatal(readErr)
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn returned %d", code)
 	}
-	if code != 0 {
-		t.Fatalf("mainfn failed with code %d, debug log:\n%s", code, string(logBytes))
+
+	head := runGitHead(t, work)
+	if got := runGit(t, origin, "rev-parse", "refs/heads/main"); got != head {
+		t.Fatalf("expected origin main at %q, got %q", head, got)
 	}
-	logText := string(logBytes)
-	if strings.Contains(logText, " fetch --") {
-		t.Fatalf("unexpected git fetch while -nofetch is set:\n%s", logText)
+	if got := runGit(t, origin, "rev-parse", "v1.0.1^{commit}"); got != head {
+		t.Fatalf("expected origin v1.0.1 at %q, got %q", head, got)
 	}
 }
 
-func TestMainFnIncPatchInTestModeDoesNotCreateTag(t *testing.T) {
+func TestMainFnIncPatchRollsBackBothRefsOnRejectedPush(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -1023,23 +1018,42 @@ func TestMainFnIncPatchInTestModeDoesNotCreateTag(t *testing.T) {
 		testMode = origTestMode
 	}()
 
-	work := t.TempDir()
-	runGit(t, work, "init", "-q")
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+	other := filepath.Join(base, "other")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "clone", "-q", origin, work)
 	runGit(t, work, "config", "user.email", "test@example.com")
 	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "checkout", "-q", "-B", "main")
 	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
 	runGit(t, work, "add", "a.txt")
 	runGit(t, work, "commit", "-q", "-m", "c1")
 	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+
+	// someone else moves main on origin, so our branch push is not a fast-forward
+	runGit(t, "", "clone", "-q", origin, other)
+	runGit(t, other, "config", "user.email", "test@example.com")
+	runGit(t, other, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(other, "b.txt"), []byte("b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, other, "add", "b.txt")
+	runGit(t, other, "commit", "-q", "-m", "c2")
+	runGit(t, other, "push", "-q", "origin", "main")
+	originMain := runGit(t, origin, "rev-parse", "refs/heads/main")
 
 	if err := os.Chdir(work); err != nil {
 		t.Fatal(err)
 	}
 
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
 	*flagDebug = false
 	*flagGoPackage = false
@@ -1048,19 +1062,78 @@ func TestMainFnIncPatchInTestModeDoesNotCreateTag(t *testing.T) {
 	*flagIncPatch = true
 	*flagIncMinor = false
 	*flagBranch = false
-	testMode = true
+	testMode = false
 
-	if code := mainfn(); code != 0 {
-		t.Fatalf("mainfn failed with code %d", code)
+	preHead := runGitHead(t, work)
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
 	}
 
-	localTags := runGit(t, work, "tag", "--list")
-	if strings.Contains(localTags, "v1.0.1") {
-		t.Fatalf("unexpected local tag v1.0.1 in test mode: %q", localTags)
+	if got := runGit(t, origin, "rev-parse", "refs/heads/main"); got != originMain {
+		t.Fatalf("expected origin main to stay at %q, got %q", originMain, got)
+	}
+	if remoteTags := runGit(t, origin, "tag", "--list"); strings.Contains(remoteTags, "v1.0.1") {
+		t.Fatalf("unexpected remote tag v1.0.1: %q", remoteTags)
+	}
+	if localTags := runGit(t, work, "tag", "--list"); strings.Contains(localTags, "v1.0.1") {
+		t.Fatalf("unexpected local tag v1.0.1: %q", localTags)
+	}
+	if afterHead := runGitHead(t, work); afterHead != preHead {
+		t.Fatalf("expected HEAD to roll back to %q, got %q", preHead, afterHead)
+	}
+	if _, err := os.Stat(filepath.Join(work, "version.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected version.txt to be rolled back, got %v", err)
 	}
 }
 
-func TestMainFnRejectsIncPatchAndIncMinor(t *testing.T) {
+// setupPushRace returns a clone of origin at v1.0.0, with another clone
+// that pushes its own release of v1.0.1 as soon as v1.0.1 is tagged in work.
+func setupPushRace(t *testing.T) (origin, work, other string) {
+	t.Helper()
+	base := t.TempDir()
+	origin = filepath.Join(base, "origin.git")
+	work = filepath.Join(base, "work")
+	other = filepath.Join(base, "other")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "checkout", "-q", "-B", "main")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+
+	// the other job has made its release of v1.0.1, but not pushed it yet
+	runGit(t, "", "clone", "-q", origin, other)
+	runGit(t, other, "config", "user.email", "test@example.com")
+	runGit(t, other, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(other, "version.txt"), []byte("v1.0.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, other, "add", "version.txt")
+	runGit(t, other, "commit", "-q", "-m", "release v1.0.1")
+	runGit(t, other, "tag", "v1.0.1")
+
+	// it pushes as soon as we have tagged v1.0.1 locally, so that our push loses the race
+	hook := "#!/bin/sh\n" +
+		"[ \"$1\" = committed ] || exit 0\n" +
+		"grep -q ' refs/tags/v1.0.1$' || exit 0\n" +
+		"[ -e \"$0.done\" ] && exit 0\n" +
+		"touch \"$0.done\"\n" +
+		"unset $(git rev-parse --local-env-vars)\n" +
+		"git -C '" + other + "' push -q --atomic origin main v1.0.1\n"
+	if err := os.WriteFile(filepath.Join(work, ".git", "hooks", "reference-transaction"), []byte(hook), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestMainFnIncPatchRetriesWhenAnotherJobPushesFirst(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -1081,47 +1154,51 @@ func TestMainFnRejectsIncPatchAndIncMinor(t *testing.T) {
 		testMode = origTestMode
 	}()
 
-	work := t.TempDir()
-	runGit(t, work, "init", "-q")
-	runGit(t, work, "config", "user.email", "test@example.com")
-	runGit(t, work, "config", "user.name", "Test")
-	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	runGit(t, work, "add", "a.txt")
-	runGit(t, work, "commit", "-q", "-m", "c1")
-	runGit(t, work, "tag", "v1.0.0")
+	origin, work, other := setupPushRace(t)
+
 	if err := os.Chdir(work); err != nil {
 		t.Fatal(err)
 	}
 
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
 	*flagDebug = false
 	*flagGoPackage = false
-	*flagNoFetch = true
+	*flagNoFetch = false
 	*flagNoNewline = false
 	*flagIncPatch = true
-	*flagIncMinor = true
+	*flagIncMinor = false
 	*flagBranch = false
 	testMode = false
 
-	if code := mainfn(); code == 0 {
-		t.Fatal("mainfn unexpectedly succeeded")
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn returned %d", code)
 	}
-	if _, err := os.Stat(filepath.Join(work, "out.txt")); err == nil {
-		t.Fatal("unexpected output file out.txt")
-	} else if !os.IsNotExist(err) {
+
+	head := runGitHead(t, work)
+	if got := runGit(t, origin, "rev-parse", "refs/heads/main"); got != head {
+		t.Fatalf("expected origin main at %q, got %q", head, got)
+	}
+	if got := runGit(t, origin, "rev-parse", "v1.0.2^{commit}"); got != head {
+		t.Fatalf("expected origin v1.0.2 at %q, got %q", head, got)
+	}
+	if got, want := runGit(t, origin, "rev-parse", "v1.0.1^{commit}"), runGit(t, other, "rev-parse", "HEAD"); got != want {
+		t.Fatalf("expected origin v1.0.1 at %q, got %q", want, got)
+	}
+	if got, want := runGit(t, work, "rev-parse", "HEAD~1"), runGit(t, other, "rev-parse", "HEAD"); got != want {
+		t.Fatalf("expected our release on top of the other one at %q, got %q", want, got)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "version.txt"))
+	if err != nil {
 		t.Fatal(err)
 	}
-	localTags := runGit(t, work, "tag", "--list")
-	if strings.Contains(localTags, "v1.0.1") || strings.Contains(localTags, "v1.1.0") {
-		t.Fatalf("unexpected incremented tags: %q", localTags)
+	if got := string(b); got != "v1.0.2\n" {
+		t.Fatalf("expected version.txt to have v1.0.2, got %q", got)
 	}
 }
 
-func TestMainFnIncMinorOverwritesExistingOutputFile(t *testing.T) {
+func TestMainFnIncPatchRetryWritesCIOutputOnce(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -1133,79 +1210,81 @@ func TestMainFnIncMinorOverwritesExistingOutputFile(t *testing.T) {
 	origDebug, origGoPackage := *flagDebug, *flagGoPackage
 	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
 	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origCIOutput := *flagCIOutput
 	origTestMode := testMode
 	defer func() {
 		*flagGit, *flagOut, *flagName = origGit, origOut, origName
 		*flagDebug, *flagGoPackage = origDebug, origGoPackage
 		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
 		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagCIOutput = origCIOutput
 		testMode = origTestMode
 	}()
 
-	base := t.TempDir()
-	origin := filepath.Join(base, "origin.git")
-	work := filepath.Join(base, "work")
-
-	runGit(t, "", "init", "--bare", "-q", origin)
-	runGit(t, "", "clone", "-q", origin, work)
-	runGit(t, work, "config", "user.email", "test@example.com")
-	runGit(t, work, "config", "user.name", "Test")
-	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	runGit(t, work, "add", "a.txt")
-	runGit(t, work, "commit", "-q", "-m", "c1")
-	runGit(t, work, "tag", "v1.0.0")
-	runGit(t, work, "push", "-q", "origin", "HEAD", "--tags")
-
-	if err := os.WriteFile(filepath.Join(work, "out.txt"), []byte("old\n"), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	runGit(t, work, "add", "out.txt")
-	runGit(t, work, "commit", "-q", "-m", "add out")
-
-	if err := os.Chdir(work); err != nil {
-		t.Fatal(err)
-	}
-
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
 	*flagDebug = false
 	*flagGoPackage = false
-	*flagNoFetch = true
+	*flagNoFetch = false
 	*flagNoNewline = false
-	*flagIncPatch = false
-	*flagIncMinor = true
+	*flagIncPatch = true
+	*flagIncMinor = false
 	*flagBranch = false
+	*flagCIOutput = true
 	testMode = false
 
-	if code := mainfn(); code != 0 {
-		t.Fatalf("mainfn failed with code %d", code)
-	}
+	t.Run("GitLab", func(t *testing.T) {
+		origin, work, _ := setupPushRace(t)
+		defer func() { _ = os.Chdir(oldWD) }()
+		if err := os.Chdir(work); err != nil {
+			t.Fatal(err)
+		}
+		// the dotenv report goes into the checkout, where it must not stop the retry
+		t.Setenv("GITHUB_ACTIONS", "")
+		t.Setenv("GITLAB_CI", "true")
+		t.Setenv("CI_COMMIT_REF_NAME", "main")
+		t.Setenv("CI_PROJECT_DIR", work)
+		if code := mainfn(); code != 0 {
+			t.Fatalf("mainfn returned %d", code)
+		}
+		if got := runGit(t, origin, "rev-parse", "v1.0.2^{commit}"); got != runGitHead(t, work) {
+			t.Fatalf("expected origin v1.0.2 at HEAD, got %q", got)
+		}
+		b, err := os.ReadFile(filepath.Join(work, "gitsemver.env"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), "GITSEMVER_VERSION=v1.0.2\n") {
+			t.Fatalf("unexpected dotenv content %q", b)
+		}
+	})
 
-	out, err := os.ReadFile(filepath.Join(work, "out.txt"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	if strings.Contains(string(out), "old") {
-		t.Fatalf("expected out.txt to be replaced, got %q", string(out))
-	}
-	if !strings.Contains(string(out), "v1.1.0") {
-		t.Fatalf("expected out.txt to contain new version, got %q", string(out))
-	}
-	remoteTags := runGit(t, work, "ls-remote", "--tags", "origin")
-	if !strings.Contains(remoteTags, "refs/tags/v1.1.0") {
-		t.Fatalf("expected remote tag v1.1.0, got %q", remoteTags)
-	}
-	subject := runGit(t, work, "show", "-s", "--format=%s", "HEAD")
-	expectedSubject := gitsemver.MakeCommitMessage("v1.1.0")
-	if subject != expectedSubject {
-		t.Fatalf("expected commit subject %q, got %q", expectedSubject, subject)
-	}
+	t.Run("GitHub", func(t *testing.T) {
+		_, work, _ := setupPushRace(t)
+		defer func() { _ = os.Chdir(oldWD) }()
+		if err := os.Chdir(work); err != nil {
+			t.Fatal(err)
+		}
+		ghOutput := filepath.Join(t.TempDir(), "github_output")
+		t.Setenv("GITHUB_ACTIONS", "true")
+		t.Setenv("GITLAB_CI", "")
+		t.Setenv("GITHUB_OUTPUT", ghOutput)
+		t.Setenv("GITHUB_ENV", "")
+		if code := mainfn(); code != 0 {
+			t.Fatalf("mainfn returned %d", code)
+		}
+		b, err := os.ReadFile(ghOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(b), "version<<"); n != 1 || !strings.Contains(string(b), "\nv1.0.2\n") {
+			t.Fatalf("expected one version v1.0.2 in GITHUB_OUTPUT, got %q", b)
+		}
+	})
 }
 
-func TestMainFnIncMinorDoesNotWriteOutputOnPushError(t *testing.T) {
+func TestMainFnIncPatchPushesToEveryRemote(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -1217,66 +1296,72 @@ func TestMainFnIncMinorDoesNotWriteOutputOnPushError(t *testing.T) {
 	origDebug, origGoPackage := *flagDebug, *flagGoPackage
 	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
 	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origRemote, origTagSource, origNoConfig := *flagRemote, *flagTagSource, *flagNoConfig
 	origTestMode := testMode
 	defer func() {
 		*flagGit, *flagOut, *flagName = origGit, origOut, origName
 		*flagDebug, *flagGoPackage = origDebug, origGoPackage
 		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
 		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagRemote, *flagTagSource, *flagNoConfig = origRemote, origTagSource, origNoConfig
 		testMode = origTestMode
 	}()
 
-	work := t.TempDir()
-	runGit(t, work, "init", "-q")
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	mirror := filepath.Join(base, "mirror.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", mirror)
+	runGit(t, "", "clone", "-q", origin, work)
 	runGit(t, work, "config", "user.email", "test@example.com")
 	runGit(t, work, "config", "user.name", "Test")
-	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+	runGit(t, work, "remote", "add", "mirror", mirror)
+	runGit(t, work, "checkout", "-q", "-B", "main")
+	// the remotes come from the config file
+	if err := os.WriteFile(filepath.Join(work, ".gitsemver.yaml"), []byte("remote: [origin, mirror]\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "add", ".gitsemver.yaml")
 	runGit(t, work, "commit", "-q", "-m", "c1")
 	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+	runGit(t, work, "push", "-q", "mirror", "main", "--tags")
 
 	if err := os.Chdir(work); err != nil {
 		t.Fatal(err)
 	}
 
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
 	*flagDebug = false
 	*flagGoPackage = false
 	*flagNoFetch = true
 	*flagNoNewline = false
-	*flagIncPatch = false
-	*flagIncMinor = true
+	*flagIncPatch = true
+	*flagIncMinor = false
 	*flagBranch = false
+	*flagRemote, *flagTagSource, *flagNoConfig = nil, "", false
 	testMode = false
 
-	preHead := runGitHead(t, work)
-	if code := mainfn(); code == 0 {
-		t.Fatal("mainfn unexpectedly succeeded")
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn returned %d", code)
 	}
 
-	if _, err := os.Stat(filepath.Join(work, "out.txt")); err == nil {
-		t.Fatal("unexpected output file out.txt")
-	} else if !os.IsNotExist(err) {
-		t.Fatal(err)
-	}
-	localTags := runGit(t, work, "tag", "--list")
-	if strings.Contains(localTags, "v1.1.0") {
-		t.Fatalf("unexpected local tag v1.1.0: %q", localTags)
-	}
-	afterHead := runGitHead(t, work)
-	if afterHead != preHead {
-		t.Fatalf("expected HEAD to roll back to %q, got %q", preHead, afterHead)
-	}
-	if status := runGit(t, work, "status", "--porcelain"); status != "" {
-		t.Fatalf("expected clean status after rollback, got %q", status)
+	head := runGitHead(t, work)
+	for _, remote := range []string{origin, mirror} {
+		if got := runGit(t, remote, "rev-parse", "refs/heads/main"); got != head {
+			t.Fatalf("expected %s main at %q, got %q", remote, head, got)
+		}
+		if got := runGit(t, remote, "rev-parse", "v1.0.1^{commit}"); got != head {
+			t.Fatalf("expected %s v1.0.1 at %q, got %q", remote, head, got)
+		}
 	}
 }
 
-func TestMainFnIncMinorInTestModeDoesNotCreateTag(t *testing.T) {
+func TestMainFnIncPatchRollsBackEveryRemoteOnPushError(t *testing.T) {
 	flag.Parse()
 	oldWD, err := os.Getwd()
 	if err != nil {
@@ -1288,48 +1373,2046 @@ func TestMainFnIncMinorInTestModeDoesNotCreateTag(t *testing.T) {
 	origDebug, origGoPackage := *flagDebug, *flagGoPackage
 	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
 	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origRemote, origTagSource := *flagRemote, *flagTagSource
 	origTestMode := testMode
 	defer func() {
 		*flagGit, *flagOut, *flagName = origGit, origOut, origName
 		*flagDebug, *flagGoPackage = origDebug, origGoPackage
 		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
 		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagRemote, *flagTagSource = origRemote, origTagSource
 		testMode = origTestMode
 	}()
 
-	work := t.TempDir()
-	runGit(t, work, "init", "-q")
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	mirror := filepath.Join(base, "mirror.git")
+	work := filepath.Join(base, "work")
+	other := filepath.Join(base, "other")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", mirror)
+	runGit(t, "", "clone", "-q", origin, work)
 	runGit(t, work, "config", "user.email", "test@example.com")
 	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "remote", "add", "mirror", mirror)
+	runGit(t, work, "checkout", "-q", "-B", "main")
 	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
 	runGit(t, work, "add", "a.txt")
 	runGit(t, work, "commit", "-q", "-m", "c1")
 	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+	runGit(t, work, "push", "-q", "mirror", "main", "--tags")
+
+	// main moves on in the mirror only, so the push to it fails after the one to origin
+	runGit(t, "", "clone", "-q", mirror, other)
+	runGit(t, other, "config", "user.email", "test@example.com")
+	runGit(t, other, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(other, "b.txt"), []byte("b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, other, "add", "b.txt")
+	runGit(t, other, "commit", "-q", "-m", "c2")
+	runGit(t, other, "push", "-q", "origin", "main")
+	mirrorMain := runGit(t, mirror, "rev-parse", "refs/heads/main")
 
 	if err := os.Chdir(work); err != nil {
 		t.Fatal(err)
 	}
 
 	*flagGit = "git"
-	*flagOut = "out.txt"
+	*flagOut = "version.txt"
 	*flagName = ""
 	*flagDebug = false
 	*flagGoPackage = false
 	*flagNoFetch = true
 	*flagNoNewline = false
-	*flagIncPatch = false
-	*flagIncMinor = true
+	*flagIncPatch = true
+	*flagIncMinor = false
 	*flagBranch = false
-	testMode = true
+	*flagRemote, *flagTagSource = stringList{"origin", "mirror"}, ""
+	testMode = false
 
-	if code := mainfn(); code != 0 {
-		t.Fatalf("mainfn failed with code %d", code)
+	preHead := runGitHead(t, work)
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
 	}
 
-	localTags := runGit(t, work, "tag", "--list")
-	if strings.Contains(localTags, "v1.1.0") {
-		t.Fatalf("unexpected local tag v1.1.0 in test mode: %q", localTags)
+	if got := runGit(t, origin, "rev-parse", "refs/heads/main"); got != preHead {
+		t.Fatalf("expected origin main to roll back to %q, got %q", preHead, got)
+	}
+	if got := runGit(t, mirror, "rev-parse", "refs/heads/main"); got != mirrorMain {
+		t.Fatalf("expected mirror main to stay at %q, got %q", mirrorMain, got)
+	}
+	for _, dir := range []string{origin, mirror, work} {
+		if tags := runGit(t, dir, "tag", "--list"); strings.Contains(tags, "v1.0.1") {
+			t.Fatalf("unexpected tag v1.0.1 in %s: %q", dir, tags)
+		}
+	}
+	if afterHead := runGitHead(t, work); afterHead != preHead {
+		t.Fatalf("expected HEAD to roll back to %q, got %q", preHead, afterHead)
+	}
+}
+
+func TestPushTagRetriesOnlyFirstRemoteRejection(t *testing.T) {
+	origRemote := *flagRemote
+	defer func() { *flagRemote = origRemote }()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	mirror := filepath.Join(base, "mirror.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", mirror)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "remote", "add", "mirror", mirror)
+	runGit(t, work, "checkout", "-q", "-B", "main")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+
+	// the mirror already has v1.0.1, which origin doesn't, so a retry
+	// computing the version from origin's tags would get v1.0.1 again
+	runGit(t, work, "tag", "v1.0.1")
+	runGit(t, work, "push", "-q", "mirror", "v1.0.1")
+	runGit(t, work, "tag", "-d", "v1.0.1")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "c2")
+	runGit(t, work, "push", "-q", "origin", "main")
+	runGit(t, work, "push", "-q", "mirror", "main")
+	preHead := runGitHead(t, work)
+	runGit(t, work, "tag", "v1.0.1")
+
+	vs, err := gitsemver.New("git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*flagRemote = stringList{"mirror", "origin"}
+	err = pushTag(vs, work, "v1.0.1", "", preHead)
+	if !errors.Is(err, gitsemver.ErrPushRejected) {
+		t.Fatalf("expected a retryable ErrPushRejected from the first remote, got %v", err)
+	}
+
+	*flagRemote = stringList{"origin", "mirror"}
+	err = pushTag(vs, work, "v1.0.1", "", preHead)
+	if err == nil {
+		t.Fatal("pushTag unexpectedly succeeded")
+	}
+	if errors.Is(err, gitsemver.ErrPushRejected) {
+		t.Fatalf("expected a rejection by a later remote not to be retried, got %v", err)
+	}
+	if tags := runGit(t, origin, "tag", "--list"); tags != "" {
+		t.Fatalf("expected v1.0.1 to be rolled back from origin, got %q", tags)
+	}
+}
+
+func TestMainFnTagSourceRemote(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origRemote, origTagSource := *flagRemote, *flagTagSource
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagRemote, *flagTagSource = origRemote, origTagSource
+		testMode = origTestMode
+	}()
+
+	base := t.TempDir()
+	upstream := filepath.Join(base, "upstream.git")
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", upstream)
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "remote", "add", "upstream", upstream)
+	runGit(t, work, "checkout", "-q", "-B", "main")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+	// only upstream has the latest release
+	runGit(t, work, "tag", "v1.1.0")
+	runGit(t, work, "push", "-q", "upstream", "main", "--tags")
+	runGit(t, work, "tag", "-d", "v1.1.0")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "version.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = false
+	*flagNoNewline = false
+	*flagIncPatch = true
+	*flagIncMinor = false
+	*flagBranch = false
+	*flagRemote, *flagTagSource = stringList{"origin"}, "upstream"
+	testMode = false
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn returned %d", code)
+	}
+
+	head := runGitHead(t, work)
+	if got := runGit(t, origin, "rev-parse", "v1.1.1^{commit}"); got != head {
+		t.Fatalf("expected origin v1.1.1 at %q, got %q", head, got)
+	}
+	if tags := runGit(t, upstream, "tag", "--list"); strings.Contains(tags, "v1.1.1") {
+		t.Fatalf("unexpected upstream tag v1.1.1: %q", tags)
+	}
+}
+
+func TestMainFnNoFetchDoesNotRunAnyFetch(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "commit", "-qam", "c2")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = true
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = false
+	*flagBranch = false
+	testMode = true
+
+	origStderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	code := mainfn()
+	os.Stderr = origStderr
+	_ = w.Close()
+	logBytes, readErr := io.ReadAll(r)
+	_ = r.Close()
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if code != 0 {
+		t.Fatalf("mainfn failed with code %d, debug log:\n%s", code, string(logBytes))
+	}
+	logText := string(logBytes)
+	if strings.Contains(logText, " fetch --") {
+		t.Fatalf("unexpected git fetch while -nofetch is set:\n%s", logText)
+	}
+}
+
+func TestMainFnIncPatchInTestModeDoesNotCreateTag(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = true
+	*flagIncMinor = false
+	*flagBranch = false
+	testMode = true
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+
+	localTags := runGit(t, work, "tag", "--list")
+	if strings.Contains(localTags, "v1.0.1") {
+		t.Fatalf("unexpected local tag v1.0.1 in test mode: %q", localTags)
+	}
+}
+
+func TestMainFnRejectsIncPatchAndIncMinor(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = true
+	*flagIncMinor = true
+	*flagBranch = false
+	testMode = false
+
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(work, "out.txt")); err == nil {
+		t.Fatal("unexpected output file out.txt")
+	} else if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	localTags := runGit(t, work, "tag", "--list")
+	if strings.Contains(localTags, "v1.0.1") || strings.Contains(localTags, "v1.1.0") {
+		t.Fatalf("unexpected incremented tags: %q", localTags)
+	}
+}
+
+func TestMainFnIncMinorOverwritesExistingOutputFile(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "push", "-q", "origin", "HEAD", "--tags")
+
+	if err := os.WriteFile(filepath.Join(work, "out.txt"), []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "out.txt")
+	runGit(t, work, "commit", "-q", "-m", "add out")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = true
+	*flagBranch = false
+	testMode = false
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+
+	out, err := os.ReadFile(filepath.Join(work, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "old") {
+		t.Fatalf("expected out.txt to be replaced, got %q", string(out))
+	}
+	if !strings.Contains(string(out), "v1.1.0") {
+		t.Fatalf("expected out.txt to contain new version, got %q", string(out))
+	}
+	remoteTags := runGit(t, work, "ls-remote", "--tags", "origin")
+	if !strings.Contains(remoteTags, "refs/tags/v1.1.0") {
+		t.Fatalf("expected remote tag v1.1.0, got %q", remoteTags)
+	}
+	subject := runGit(t, work, "show", "-s", "--format=%s", "HEAD")
+	expectedSubject := gitsemver.MakeCommitMessage("v1.1.0")
+	if subject != expectedSubject {
+		t.Fatalf("expected commit subject %q, got %q", expectedSubject, subject)
+	}
+}
+
+func TestMainFnIncMinorDoesNotWriteOutputOnPushError(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = true
+	*flagBranch = false
+	testMode = false
+
+	preHead := runGitHead(t, work)
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
+	}
+
+	if _, err := os.Stat(filepath.Join(work, "out.txt")); err == nil {
+		t.Fatal("unexpected output file out.txt")
+	} else if !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	localTags := runGit(t, work, "tag", "--list")
+	if strings.Contains(localTags, "v1.1.0") {
+		t.Fatalf("unexpected local tag v1.1.0: %q", localTags)
+	}
+	afterHead := runGitHead(t, work)
+	if afterHead != preHead {
+		t.Fatalf("expected HEAD to roll back to %q, got %q", preHead, afterHead)
+	}
+	if status := runGit(t, work, "status", "--porcelain"); status != "" {
+		t.Fatalf("expected clean status after rollback, got %q", status)
+	}
+}
+
+func TestMainFnIncMinorInTestModeDoesNotCreateTag(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = true
+	*flagBranch = false
+	testMode = true
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+
+	localTags := runGit(t, work, "tag", "--list")
+	if strings.Contains(localTags, "v1.1.0") {
+		t.Fatalf("unexpected local tag v1.1.0 in test mode: %q", localTags)
+	}
+}
+
+func TestMainFnIncMajorChecksModulePath(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origIncMajor := *flagIncMajor
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagIncMajor = origIncMajor
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "go.mod"), []byte("module example.com/gitsemvertest\n\ngo 1.24\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "go.mod", ".gitignore")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.3")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = false
+	*flagIncMajor = true
+	*flagBranch = false
+	testMode = false
+
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded without /v2 module suffix")
+	}
+	if localTags := runGit(t, work, "tag", "--list"); strings.Contains(localTags, "v2.0.0") {
+		t.Fatalf("unexpected local tag v2.0.0: %q", localTags)
+	}
+
+	if err := os.WriteFile(filepath.Join(work, "go.mod"), []byte("module example.com/gitsemvertest/v2\n\ngo 1.24\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "commit", "-qam", "c2")
+	testMode = true
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "v2.0.0\n" {
+		t.Fatalf("expected v2.0.0, got %q", got)
+	}
+}
+
+func TestMainFnRejectsIncPatchAndIncMajor(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut := *flagNoFetch, *flagOut
+	origIncPatch, origIncMajor := *flagIncPatch, *flagIncMajor
+	defer func() {
+		*flagNoFetch, *flagOut = origNoFetch, origOut
+		*flagIncPatch, *flagIncMajor = origIncPatch, origIncMajor
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = ""
+	*flagIncPatch = true
+	*flagIncMajor = true
+
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
+	}
+}
+
+func TestMainFnIncAuto(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origIncAuto := *flagIncAuto
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagIncAuto = origIncAuto
+		testMode = origTestMode
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".gitignore")
+	runGit(t, work, "commit", "-q", "-m", "initial")
+	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "chore: tidy up")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = "out.txt"
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = false
+	*flagIncAuto = true
+	*flagBranch = false
+	testMode = true
+
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded with nothing to release")
+	}
+
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "fix: a bug")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "feat(cli): a feature")
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "v1.1.0\n" {
+		t.Fatalf("expected v1.1.0, got %q", got)
+	}
+}
+
+func TestMainFnPrereleaseAndPromote(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origOut, origName := *flagGit, *flagOut, *flagName
+	origDebug, origGoPackage := *flagDebug, *flagGoPackage
+	origNoFetch, origNoNewline := *flagNoFetch, *flagNoNewline
+	origIncPatch, origIncMinor, origBranch := *flagIncPatch, *flagIncMinor, *flagBranch
+	origPrerelease, origPromote := *flagPrerelease, *flagPromote
+	origTestMode := testMode
+	defer func() {
+		*flagGit, *flagOut, *flagName = origGit, origOut, origName
+		*flagDebug, *flagGoPackage = origDebug, origGoPackage
+		*flagNoFetch, *flagNoNewline = origNoFetch, origNoNewline
+		*flagIncPatch, *flagIncMinor, *flagBranch = origIncPatch, origIncMinor, origBranch
+		*flagPrerelease, *flagPromote = origPrerelease, origPromote
+		testMode = origTestMode
+	}()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+
+	runGit(t, "", "init", "--bare", "-q", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.3")
+	runGit(t, work, "push", "-q", "origin", "HEAD", "--tags")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "c2")
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGit = "git"
+	*flagOut = ""
+	*flagName = ""
+	*flagDebug = false
+	*flagGoPackage = false
+	*flagNoFetch = true
+	*flagNoNewline = false
+	*flagIncPatch = false
+	*flagIncMinor = true
+	*flagBranch = false
+	*flagPrerelease = "rc"
+	*flagPromote = false
+	testMode = false
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "c3")
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	remoteTags := runGit(t, work, "ls-remote", "--tags", "origin")
+	if !strings.Contains(remoteTags, "refs/tags/v1.3.0-rc.1") || !strings.Contains(remoteTags, "refs/tags/v1.3.0-rc.2") {
+		t.Fatalf("expected remote tags v1.3.0-rc.1 and v1.3.0-rc.2, got %q", remoteTags)
+	}
+
+	*flagIncMinor = false
+	*flagPrerelease = ""
+	*flagPromote = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got, want := runGit(t, work, "rev-parse", "v1.3.0^{commit}"), runGit(t, work, "rev-parse", "v1.3.0-rc.2^{commit}"); got != want {
+		t.Fatalf("expected v1.3.0 to tag the same commit as v1.3.0-rc.2, got %q want %q", got, want)
+	}
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly promoted a release tag")
+	}
+
+	*flagPrerelease = "rc"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -promote with -prerelease")
+	}
+	*flagPromote = false
+	*flagPrerelease = "r.c"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted an invalid prerelease channel")
+	}
+}
+
+func TestMainFnStyle(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origStyle, origSafe := *flagNoFetch, *flagOut, *flagStyle, *flagSafe
+	defer func() {
+		*flagNoFetch, *flagOut, *flagStyle, *flagSafe = origNoFetch, origOut, origStyle, origSafe
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".gitignore")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.3")
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c2")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "out.txt"
+	*flagStyle = "nextpatch"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "v1.2.4-0.main.2\n" {
+		t.Fatalf("expected v1.2.4-0.main.2, got %q", got)
+	}
+
+	*flagStyle = "metadata"
+	*flagSafe = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if b, err = os.ReadFile(filepath.Join(work, "out.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "v1.2.3_main.2\n" {
+		t.Fatalf("expected v1.2.3_main.2, got %q", got)
+	}
+
+	*flagStyle = "bogus"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted an unknown style")
+	}
+}
+
+func TestMainFnFormatJSON(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origFormat, origBranch := *flagNoFetch, *flagOut, *flagFormat, *flagBranch
+	defer func() {
+		*flagNoFetch, *flagOut, *flagFormat, *flagBranch = origNoFetch, origOut, origFormat, origBranch
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".gitignore")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.3")
+	tagCommit := runGitHead(t, work)
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "a.txt")
+	runGit(t, work, "commit", "-q", "-m", "c2")
+	headCommit := runGitHead(t, work)
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "out.json"
+	*flagFormat = "json"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "out.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got gitsemver.VersionJSON
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "v1.2.3-main.2" || got.Tag != "v1.2.3" || got.CleanBranch != "main" || got.SameTree {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+	if got.Commit != headCommit || got.TagCommit != tagCommit || got.Tree == "" {
+		t.Fatalf("unexpected hashes in %s", b)
+	}
+
+	*flagBranch = true
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -format json with -branch")
+	}
+	*flagBranch = false
+
+	*flagFormat = "yaml"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted an unknown format")
+	}
+}
+
+func TestMainFnFormatJSONIncPatch(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origFormat, origIncPatch := *flagNoFetch, *flagOut, *flagFormat, *flagIncPatch
+	origTestMode := testMode
+	defer func() {
+		*flagNoFetch, *flagOut, *flagFormat, *flagIncPatch = origNoFetch, origOut, origFormat, origIncPatch
+		testMode = origTestMode
+	}()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+	runGit(t, "", "init", "--bare", "-q", "-b", "main", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	runGit(t, work, "checkout", "-q", "-B", "main")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.3")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "c2")
+	runGit(t, work, "push", "-q", "origin", "main", "--tags")
+	headCommit := runGitHead(t, work)
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = ""
+	*flagFormat = "json"
+	*flagIncPatch = true
+	testMode = false
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	code := mainfn()
+	os.Stdout = oldStdout
+	_ = w.Close()
+	if code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got gitsemver.VersionJSON
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "v1.2.4" || got.Tag != "v1.2.4" || !got.SameTree {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+	if got.Commit != headCommit || got.TagCommit != headCommit {
+		t.Fatalf("expected commit and tag_commit %q in %s", headCommit, b)
+	}
+	if tagged := runGit(t, work, "rev-parse", "v1.2.4^{commit}"); tagged != got.TagCommit {
+		t.Fatalf("tag_commit %q, but v1.2.4 is on %q", got.TagCommit, tagged)
+	}
+}
+
+func TestMainFnTemplate(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origTemplate, origGoPackage := *flagNoFetch, *flagOut, *flagTemplate, *flagGoPackage
+	defer func() {
+		*flagNoFetch, *flagOut, *flagTemplate, *flagGoPackage = origNoFetch, origOut, origTemplate, origGoPackage
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	tmpl := "VERSION = \"{{.Version}}\"\nMAJOR = {{major .Tag}}\nCOMMIT = \"{{shortCommit .Commit}}\"\nDATE = \"{{commitDate .CommitTime}}\"\n"
+	if err := os.WriteFile(filepath.Join(work, "_version.py.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("_version.py\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v2.1.0")
+	head := runGitHead(t, work)
+	when, err := time.Parse(time.RFC3339, runGit(t, work, "show", "-s", "--format=%cI", "HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "_version.py"
+	*flagTemplate = "_version.py.tmpl"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "_version.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "VERSION = \"v2.1.0\"\nMAJOR = 2\nCOMMIT = \"" + head[:7] + "\"\nDATE = \"" + when.UTC().Format(time.RFC3339) + "\"\n"
+	if got := string(b); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	*flagGoPackage = true
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -template with -gopackage")
+	}
+	*flagGoPackage = false
+
+	*flagTemplate = "missing.tmpl"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted a missing template")
+	}
+}
+
+func TestMainFnCIOutput(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origCIOutput := *flagNoFetch, *flagOut, *flagCIOutput
+	defer func() {
+		*flagNoFetch, *flagOut, *flagCIOutput = origNoFetch, origOut, origCIOutput
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".gitignore")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	ghOutput := filepath.Join(t.TempDir(), "github_output")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("GITHUB_OUTPUT", ghOutput)
+	t.Setenv("GITHUB_ENV", "")
+	*flagNoFetch = true
+	*flagOut = "out.txt"
+	*flagCIOutput = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(ghOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "version<<ghadelimiter_") || !strings.Contains(string(b), "\nv1.0.0\n") {
+		t.Fatalf("unexpected GITHUB_OUTPUT content %q", b)
+	}
+
+	// nothing is published for a tag that failed to push, here for lack of a remote
+	origIncPatch, origTestMode := *flagIncPatch, testMode
+	defer func() { *flagIncPatch, testMode = origIncPatch, origTestMode }()
+	failedOutput := filepath.Join(t.TempDir(), "github_output")
+	t.Setenv("GITHUB_OUTPUT", failedOutput)
+	*flagOut = ""
+	*flagIncPatch = true
+	testMode = false
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly succeeded")
+	}
+	if _, err := os.Stat(failedOutput); !os.IsNotExist(err) {
+		t.Fatalf("expected no GITHUB_OUTPUT after a failed push, got %v", err)
+	}
+}
+
+func TestMainFnGoFields(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origGoPackage, origGoFields := *flagNoFetch, *flagOut, *flagGoPackage, *flagGoFields
+	defer func() {
+		*flagNoFetch, *flagOut, *flagGoPackage, *flagGoFields = origNoFetch, origOut, origGoPackage, origGoFields
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "go.mod"), []byte("module example.com/gitsemvertest\n\ngo 1.26\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "go.mod")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	head := runGitHead(t, work)
+	when, err := time.Parse(time.RFC3339, runGit(t, work, "show", "-s", "--format=%cI", "HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "version.gen.go"
+	*flagGoPackage = true
+	*flagGoFields = "commit,committime,dirty"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, "version.gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.Contains(s, "const PkgCommit = \""+head+"\"\n") || !strings.Contains(s, "const PkgDirty = false\n") ||
+		!strings.Contains(s, "const PkgCommitTime = \""+when.UTC().Format(time.RFC3339)+"\"\n") {
+		t.Fatal(s)
+	}
+	if strings.Contains(s, "PkgTree") {
+		t.Fatal(s)
+	}
+	if _, err = format.Source(b); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagGoFields = "bogus"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted an unknown field")
+	}
+	*flagGoFields = "commit"
+	*flagGoPackage = false
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -gofields without -gopackage")
+	}
+}
+
+func TestMainFnCheck(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origCheck, origIncPatch := *flagNoFetch, *flagOut, *flagCheck, *flagIncPatch
+	defer func() {
+		*flagNoFetch, *flagOut, *flagCheck, *flagIncPatch = origNoFetch, origOut, origCheck, origIncPatch
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, "version.txt"), []byte("v1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "version.txt")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "version.txt"
+	*flagCheck = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("expected up to date file to pass, got code %d", code)
+	}
+
+	runGit(t, work, "tag", "v1.0.1")
+	runGit(t, work, "tag", "-d", "v1.0.0")
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	code := mainfn()
+	os.Stdout = stdout
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	if code != 1 {
+		t.Fatalf("expected code 1 for stale file, got %d", code)
+	}
+	if !strings.Contains(string(out), "-v1.0.0\n+v1.0.1\n") {
+		t.Fatalf("unexpected diff %q", out)
+	}
+	if b, err := os.ReadFile(filepath.Join(work, "version.txt")); err != nil || string(b) != "v1.0.0\n" {
+		t.Fatalf("-check modified the file: %q %v", b, err)
+	}
+
+	*flagIncPatch = true
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -check with -incpatch")
+	}
+	*flagIncPatch = false
+
+	*flagOut = ""
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -check without -out")
+	}
+}
+
+func TestMainFnModuleDir(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origIncPatch := *flagNoFetch, *flagOut, *flagIncPatch
+	origPrefix, origModuleDir, origFullTag := *flagPrefix, *flagModuleDir, *flagFullTag
+	origGoPackage, origTestMode, origBuildMode := *flagGoPackage, testMode, *flagBuildMode
+	defer func() {
+		*flagNoFetch, *flagOut, *flagIncPatch = origNoFetch, origOut, origIncPatch
+		*flagPrefix, *flagModuleDir, *flagFullTag = origPrefix, origModuleDir, origFullTag
+		*flagGoPackage, testMode, *flagBuildMode = origGoPackage, origTestMode, origBuildMode
+	}()
+
+	base := t.TempDir()
+	origin := filepath.Join(base, "origin.git")
+	work := filepath.Join(base, "work")
+	runGit(t, "", "init", "--bare", "-q", origin)
+	runGit(t, "", "clone", "-q", origin, work)
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	cliDir := filepath.Join(work, "tools", "cli")
+	if err := os.MkdirAll(cliDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "go.mod"), []byte("module example.com/repo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cliDir, "go.mod"), []byte("module example.com/repo/tools/cli\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	runGit(t, work, "tag", "tools/cli/v0.1.0")
+	runGit(t, work, "push", "-q", "origin", "HEAD", "--tags")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "out.txt"
+	*flagModuleDir = "tools/cli"
+	readOut := func() string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); got != "v0.1.0\n" {
+		t.Fatalf("expected v0.1.0, got %q", got)
+	}
+	*flagFullTag = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); got != "tools/cli/v0.1.0\n" {
+		t.Fatalf("expected tools/cli/v0.1.0, got %q", got)
+	}
+	*flagFullTag = false
+
+	*flagGoPackage = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); !strings.Contains(got, "package cli\n") || !strings.Contains(got, "PkgVersion = \"v0.1.0\"") {
+		t.Fatalf("unexpected Go package %q", got)
+	}
+	*flagGoPackage = false
+	_ = os.Remove(filepath.Join(work, "out.txt"))
+
+	*flagOut = ""
+	*flagIncPatch = true
+	testMode = false
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	remoteTags := runGit(t, work, "ls-remote", "--tags", "origin")
+	if !strings.Contains(remoteTags, "refs/tags/tools/cli/v0.1.1") || strings.Contains(remoteTags, "refs/tags/v1.0.1") {
+		t.Fatalf("expected remote tag tools/cli/v0.1.1, got %q", remoteTags)
+	}
+
+	*flagIncPatch = false
+	testMode = true
+	if err := os.WriteFile(filepath.Join(work, "sibling.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", "sibling.txt")
+	runGit(t, work, "commit", "-q", "-m", "sibling change")
+	*flagOut = "out.txt"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); got != "v0.1.1\n" {
+		t.Fatalf("expected a sibling change to keep v0.1.1, got %q", got)
+	}
+	*flagBuildMode = "height"
+	*flagFullTag = true
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); got != "tools/cli/v0.1.1\n" {
+		t.Fatalf("expected tools/cli/v0.1.1, got %q", got)
+	}
+	*flagFullTag = false
+
+	*flagModuleDir = ""
+	*flagBuildMode = "path"
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted -build-mode path without -module-dir")
+	}
+	*flagBuildMode = "height"
+	*flagPrefix = "tools/cli/"
+	*flagOut = "out.txt"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); !strings.HasPrefix(got, "v0.1.") || !strings.HasSuffix(got, ".1\n") {
+		t.Fatalf("expected a work-in-progress version without -module-dir, got %q", got)
+	}
+
+	// without -module-dir, the module is the nearest go.mod above the working directory
+	*flagPrefix, *flagBuildMode = "", ""
+	cmdDir := filepath.Join(cliDir, "cmd")
+	if err := os.MkdirAll(cmdDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{cliDir, cmdDir} {
+		if err := os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		if code := mainfn(); code != 0 {
+			t.Fatalf("mainfn failed with code %d in %s", code, dir)
+		}
+		if got := readOut(); got != "v0.1.1\n" {
+			t.Fatalf("expected v0.1.1 in %s, got %q", dir, got)
+		}
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); !strings.HasPrefix(got, "v1.0.0-") {
+		t.Fatalf("expected the root module version in the repository root, got %q", got)
+	}
+}
+
+func TestMainFnNestedModuleWithoutTags(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origPrefix, origModuleDir := *flagNoFetch, *flagOut, *flagPrefix, *flagModuleDir
+	defer func() {
+		*flagNoFetch, *flagOut, *flagPrefix, *flagModuleDir = origNoFetch, origOut, origPrefix, origModuleDir
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	cliDir := filepath.Join(work, "tools", "cli")
+	if err := os.MkdirAll(cliDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cliDir, "go.mod"), []byte("module example.com/repo/tools/cli\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("out.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(cliDir); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch = true
+	*flagOut = "out.txt"
+	*flagPrefix = ""
+	readOut := func() string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// the module has no tools/cli/ tags, so the repository tags are used
+	*flagModuleDir = ""
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); got != "v1.0.0\n" {
+		t.Fatalf("expected the repository version v1.0.0, got %q", got)
+	}
+
+	*flagModuleDir = "tools/cli"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut(); !strings.HasPrefix(got, "v0.0.0") {
+		t.Fatalf("expected the untagged module version with -module-dir, got %q", got)
+	}
+}
+
+func TestMainFnConfig(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origStyle, origNoConfig, origRelease := *flagNoFetch, *flagOut, *flagStyle, *flagNoConfig, *flagRelease
+	defer func() {
+		*flagNoFetch, *flagOut, *flagStyle, *flagNoConfig, *flagRelease = origNoFetch, origOut, origStyle, origNoConfig, origRelease
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "trunk")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	config := "nofetch: true\nout: version.txt\nstyle: metadata\nrelease-branches:\n  - trunk\n  - release/*\n"
+	if err := os.WriteFile(filepath.Join(work, ".gitsemver.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	readOut := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(work, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut("version.txt"); got != "v1.0.0\n" {
+		t.Fatalf("unexpected version with config: %q", got)
+	}
+	if !*flagNoFetch || *flagStyle != "metadata" {
+		t.Fatal("config didn't set the flags")
+	}
+
+	// flags given on the command line override the config file
+	*flagNoFetch, *flagOut, *flagStyle = true, "", ""
+	if err := flag.Set("out", "other.txt"); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "checkout", "-q", "-b", "feature")
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut("other.txt"); !strings.HasPrefix(got, "v1.0.0+feature.") {
+		t.Fatalf("unexpected version for non-release branch: %q", got)
+	}
+
+	*flagNoConfig = true
+	*flagStyle = ""
+	*flagRelease = nil
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut("other.txt"); !strings.HasPrefix(got, "v1.0.0-feature.") {
+		t.Fatalf("unexpected version without config: %q", got)
+	}
+
+	// -release-branches does the same without the config file
+	*flagRelease = stringList{"trunk", "feat*"}
+	if code := mainfn(); code != 0 {
+		t.Fatalf("mainfn failed with code %d", code)
+	}
+	if got := readOut("other.txt"); got != "v1.0.0\n" {
+		t.Fatalf("unexpected version with -release-branches: %q", got)
+	}
+	*flagRelease = stringList{"["}
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted a bad -release-branches pattern")
+	}
+	*flagNoConfig, *flagRelease = false, nil
+
+	if err := os.WriteFile(filepath.Join(work, ".gitsemver.yaml"), []byte("bogus: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted an unknown config key")
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitsemver.yaml"), []byte("release-branches: ['[']\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code == 0 {
+		t.Fatal("mainfn unexpectedly accepted a bad release branch pattern")
+	}
+}
+
+func TestMainFnReleaseLine(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origIncPatch, origIncMinor, origIncAuto, origRelease := *flagNoFetch, *flagOut, *flagIncPatch, *flagIncMinor, *flagIncAuto, *flagRelease
+	defer func() {
+		*flagNoFetch, *flagOut, *flagIncPatch, *flagIncMinor, *flagIncAuto, *flagRelease = origNoFetch, origOut, origIncPatch, origIncMinor, origIncAuto, origRelease
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	config := "nofetch = true\nrelease-branches = [\"main\", \"release/*\"]\n"
+	if err := os.WriteFile(filepath.Join(work, ".gitsemver.toml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.2.0")
+	commitFile := func(content, msg string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(work, "a.go"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, work, "add", "a.go")
+		runGit(t, work, "commit", "-q", "-m", msg)
+	}
+	commitFile("package a\n", "fix: c2")
+	runGit(t, work, "branch", "release/1.2")
+	runGit(t, work, "tag", "v1.3.0")
+	runGit(t, work, "checkout", "-q", "release/1.2")
+	commitFile("package a // fixed\n", "fix: backport")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagOut = "out.txt"
+	run := func() (code int, out string) {
+		t.Helper()
+		_ = os.Remove(filepath.Join(work, "out.txt"))
+		*flagRelease = nil // set again from the config file
+		if code = mainfn(); code == 0 {
+			b, err := os.ReadFile(filepath.Join(work, "out.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = string(b)
+		}
+		return
+	}
+
+	if code, out := run(); code != 0 || !strings.HasPrefix(out, "v1.2.0-release-1-2.") {
+		t.Fatalf("unexpected version on release branch: %d %q", code, out)
+	}
+	*flagIncPatch = true
+	if code, _ := run(); code != 0 {
+		t.Fatalf("-incpatch failed on release branch with code %d", code)
+	}
+	*flagIncPatch, *flagIncMinor = false, true
+	if code, _ := run(); code == 0 {
+		t.Fatal("-incminor unexpectedly left the 1.2 line")
+	}
+	*flagIncMinor, *flagIncAuto = false, true
+	if code, _ := run(); code != 0 {
+		t.Fatalf("-incauto failed on release branch with code %d", code)
+	}
+	commitFile("package a // feature\n", "feat: backport")
+	if code, _ := run(); code == 0 {
+		t.Fatal("-incauto unexpectedly released a feature in the 1.2 line")
+	}
+	*flagIncAuto = false
+
+	runGit(t, work, "checkout", "-q", "main")
+	if code, out := run(); code != 0 || out != "v1.3.0\n" {
+		t.Fatalf("unexpected version on main: %d %q", code, out)
+	}
+}
+
+func TestMainFnInternalGit(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origNoFetch, origOut, origFormat, origCatFile := *flagGit, *flagNoFetch, *flagOut, *flagFormat, *flagCatFile
+	defer func() {
+		*flagGit, *flagNoFetch, *flagOut, *flagFormat, *flagCatFile = origGit, origNoFetch, origOut, origFormat, origCatFile
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "-a", "-m", "v1.0.0", "v1.0.0")
+	runGit(t, work, "checkout", "-q", "-b", "feature")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "feat: c2")
+	runGit(t, work, "gc", "-q")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch, *flagFormat = true, "json"
+	run := func(git string, catFile bool) string {
+		t.Helper()
+		*flagGit, *flagCatFile, *flagOut = git, catFile, git+".json"
+		if code := mainfn(); code != 0 {
+			t.Fatalf("-git %s -catfile=%v failed with code %d", git, catFile, code)
+		}
+		b, err := os.ReadFile(filepath.Join(work, *flagOut))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	want := run("git", false)
+	if got := run("internal", false); got != want {
+		t.Errorf("-git internal output differs\n got: %s\nwant: %s", got, want)
+	}
+	if got := run("git", true); got != want {
+		t.Errorf("-catfile output differs\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestMainFnDescribe(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origGit, origNoFetch, origOut, origIncPatch := *flagGit, *flagNoFetch, *flagOut, *flagIncPatch
+	defer func() {
+		*flagGit, *flagNoFetch, *flagOut, *flagIncPatch = origGit, origNoFetch, origOut, origIncPatch
+		_ = flag.CommandLine.Parse(nil)
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "c1")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.txt\n*.json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "commit", "-q", "-am", "c2")
+	runGit(t, work, "tag", "v1.1.0")
+	runGit(t, work, "checkout", "-q", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte("*.txt\n*.json\n*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "commit", "-q", "-am", "c3")
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch, *flagOut = true, "out.txt"
+	for _, git := range []string{"git", "internal"} {
+		*flagGit = git
+		for rev, want := range map[string]string{"v1.0.0": "v1.0.0\n", "main": "v1.1.0\n", "feature": "v1.1.0-feature.3\n"} {
+			if err := flag.CommandLine.Parse([]string{"describe", rev}); err != nil {
+				t.Fatal(err)
+			}
+			if code := mainfn(); code != 0 {
+				t.Fatalf("-git %s describe %s failed with code %d", git, rev, code)
+			}
+			b, err := os.ReadFile(filepath.Join(work, *flagOut))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != want {
+				t.Errorf("-git %s describe %s = %q, want %q", git, rev, b, want)
+			}
+		}
+	}
+
+	if err := flag.CommandLine.Parse([]string{"describe"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code == 0 {
+		t.Error("describe without a revision succeeded")
+	}
+	*flagIncPatch = true
+	if err := flag.CommandLine.Parse([]string{"describe", "HEAD"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code == 0 {
+		t.Error("describe with -incpatch succeeded")
+	}
+}
+
+func TestMainFnLog(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origFormat, origBranch := *flagNoFetch, *flagOut, *flagFormat, *flagBranch
+	defer func() {
+		*flagNoFetch, *flagOut, *flagFormat, *flagBranch = origNoFetch, origOut, origFormat, origBranch
+		_ = flag.CommandLine.Parse(nil)
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	var commits []string
+	for i, content := range []string{"*.txt\n", "*.txt\n*.json\n"} {
+		if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, work, "add", ".")
+		runGit(t, work, "commit", "-q", "-m", "c"+strconv.Itoa(i+1))
+		commits = append(commits, strings.TrimSpace(runGit(t, work, "rev-parse", "HEAD")))
+		if i == 0 {
+			runGit(t, work, "tag", "v1.0.0")
+		}
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	if err := flag.CommandLine.Parse([]string{"log", "main"}); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch, *flagOut = true, "out.txt"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("log failed with code %d", code)
+	}
+	b, err := os.ReadFile(filepath.Join(work, *flagOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := commits[1] + " v1.0.0-main.2\n" + commits[0] + " v1.0.0\n"
+	if string(b) != want {
+		t.Errorf("log = %q, want %q", b, want)
+	}
+
+	*flagFormat, *flagOut = "json", "out.json"
+	if code := mainfn(); code != 0 {
+		t.Fatalf("log -format json failed with code %d", code)
+	}
+	if b, err = os.ReadFile(filepath.Join(work, *flagOut)); err != nil {
+		t.Fatal(err)
+	}
+	var got []gitsemver.VersionJSON
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Commit != commits[1] || got[1].Version != "v1.0.0" {
+		t.Errorf("log -format json = %s", b)
+	}
+
+	*flagFormat, *flagBranch = "text", true
+	if code := mainfn(); code == 0 {
+		t.Error("log with -branch succeeded")
+	}
+}
+
+func TestMainFnResolve(t *testing.T) {
+	flag.Parse()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(oldWD) }()
+
+	origNoFetch, origOut, origFormat := *flagNoFetch, *flagOut, *flagFormat
+	defer func() {
+		*flagNoFetch, *flagOut, *flagFormat = origNoFetch, origOut, origFormat
+		_ = flag.CommandLine.Parse(nil)
+	}()
+
+	work := t.TempDir()
+	runGit(t, work, "init", "-q")
+	runGit(t, work, "branch", "-M", "main")
+	runGit(t, work, "config", "user.email", "test@example.com")
+	runGit(t, work, "config", "user.name", "Test")
+	var commits []string
+	for i, content := range []string{"*.txt\n", "*.txt\n*.json\n", "*.txt\n*.log\n"} {
+		if err := os.WriteFile(filepath.Join(work, ".gitignore"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, work, "add", ".")
+		runGit(t, work, "commit", "-q", "-m", "c"+strconv.Itoa(i+1))
+		commits = append(commits, strings.TrimSpace(runGit(t, work, "rev-parse", "HEAD")))
+		if i == 0 {
+			runGit(t, work, "tag", "v1.0.0")
+		}
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+
+	*flagNoFetch, *flagOut = true, "out.txt"
+	for version, want := range map[string]string{"v1.0.0": commits[0], "v1.0.0-main.2": commits[1], "v1.0.0-main.3": commits[2]} {
+		if err := flag.CommandLine.Parse([]string{"resolve", version}); err != nil {
+			t.Fatal(err)
+		}
+		if code := mainfn(); code != 0 {
+			t.Fatalf("resolve %s failed with code %d", version, code)
+		}
+		b, err := os.ReadFile(filepath.Join(work, *flagOut))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want+"\n" {
+			t.Errorf("resolve %s = %q, want %q", version, b, want)
+		}
+	}
+
+	if err := flag.CommandLine.Parse([]string{"resolve", "v1.0.0-main.4"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := mainfn(); code == 0 {
+		t.Error("resolve of an unknown version succeeded")
+	}
+	*flagFormat = "json"
+	if code := mainfn(); code == 0 {
+		t.Error("resolve with -format json succeeded")
 	}
 }